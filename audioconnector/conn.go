@@ -0,0 +1,375 @@
+// Package audioconnector receives the audio that the OpenTok
+// Audio Connector streams to a websocket server. It implements
+// the small part of RFC 6455 that the connector uses: the first
+// message is a JSON text message with the headers, followed by
+// binary messages of 16 bit little endian PCM audio.
+//
+// Dial opens a websocket the same way the connector does, so a
+// receiver can be tested against a local server.
+package audioconnector
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the key of the handshake
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Message types returned by ReadMessage
+const (
+	TextMessage   = opText
+	BinaryMessage = opBinary
+)
+
+// Header is the first message sent by the connector. It holds
+// the content type of the audio and the headers set in the
+// WebSocketOptions
+type Header map[string]string
+
+// ContentType returns the content type of the audio, e.g.
+// audio/l16;rate=16000
+func (h Header) ContentType() string {
+	return h["content-type"]
+}
+
+// Rate returns the sample rate of the audio, 16000 if the
+// content type does not have one
+func (h Header) Rate() int {
+	for _, param := range strings.Split(h.ContentType(), ";") {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "rate=") {
+			if rate, err := strconv.Atoi(param[len("rate="):]); err == nil {
+				return rate
+			}
+		}
+	}
+	return 16000
+}
+
+// Conn is a websocket connection with the Audio Connector. A Conn
+// can be read by one goroutine and written by others at the same
+// time
+type Conn struct {
+	// Header is the first message of the connection
+	Header Header
+
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Upgrade accepts the websocket handshake of r and reads the
+// header message
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != "GET" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported websocket version: %s",
+			r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if len(key) == 0 {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err = conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Conn{conn: conn, reader: rw.Reader}
+	if err = c.readHeader(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Handler is an http.Handler that upgrades the requests and
+// calls the function with the connection. The connection is
+// closed when the function returns
+type Handler func(c *Conn)
+
+// ServeHTTP upgrades r and calls h
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	h(c)
+}
+
+// Dial opens a websocket to rawurl like the Audio Connector does
+// and sends header as the first message
+func Dial(rawurl string, header Header) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = net.Dial("tcp", hostPort(u, "80"))
+	case "wss":
+		conn, err = tls.Dial("tcp", hostPort(u, "443"), &tls.Config{
+			ServerName: u.Hostname(),
+		})
+	default:
+		return nil, fmt.Errorf("websocket url must start with ws:// or wss://: %s", rawurl)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := handshake(conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	data, err := json.Marshal(header)
+	if err == nil {
+		err = c.write(opText, data)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.Header = header
+	return c, nil
+}
+
+func handshake(conn net.Conn, u *url.URL) (*Conn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequest("GET", "http://"+u.Host+u.RequestURI(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err = req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket handshake failed: statusCode: %d",
+			res.StatusCode)
+	}
+	if res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("websocket handshake failed: invalid Sec-WebSocket-Accept")
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+func (c *Conn) readHeader() error {
+	opcode, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if opcode != opText {
+		return fmt.Errorf("the first message must be the JSON header")
+	}
+	var header Header
+	if err = json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("header could not be parsed: %s", err)
+	}
+	c.Header = header
+	return nil
+}
+
+// ReadMessage returns the next message, a TextMessage or a
+// BinaryMessage, and its data. Pings are
+// answered and fragmented messages are put together. It returns
+// io.EOF when the other side closes the connection
+func (c *Conn) ReadMessage() (opcode byte, data []byte, err error) {
+	var message []byte
+	messageOpcode := byte(0)
+	for {
+		f, err := readFrame(c.reader)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err = c.write(opPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.write(opClose, f.payload)
+			return 0, nil, io.EOF
+		case opText, opBinary:
+			if messageOpcode != 0 {
+				return 0, nil, fmt.Errorf("new message before the end of the previous one")
+			}
+			messageOpcode = f.opcode
+		case opContinuation:
+			if messageOpcode == 0 {
+				return 0, nil, fmt.Errorf("continuation frame without a message")
+			}
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode: %d", f.opcode)
+		}
+
+		message = append(message, f.payload...)
+		if len(message) > maxFrameSize {
+			return 0, nil, errFrameTooLarge
+		}
+		if f.fin {
+			return messageOpcode, message, nil
+		}
+	}
+}
+
+// ReadAudio returns the samples of the next audio message. Text
+// messages are skipped
+func (c *Conn) ReadAudio() ([]int16, error) {
+	for {
+		opcode, data, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if opcode != opBinary {
+			continue
+		}
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("audio message has an odd length: %d", len(data))
+		}
+		samples := make([]int16, len(data)/2)
+		for i := range samples {
+			samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		return samples, nil
+	}
+}
+
+// WriteAudio sends samples as a binary message. It is used to
+// send audio back to a bidirectional connector, or to stream
+// audio from Dial
+func (c *Conn) WriteAudio(samples []int16) error {
+	data := make([]byte, 2*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	return c.write(opBinary, data)
+}
+
+// WriteJSON sends v as a JSON text message, e.g. a command of a
+// bidirectional connector
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(opText, data)
+}
+
+// Close sends a close message and closes the connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	// 1000 is the normal closure status code
+	writeFrame(c.conn, opClose, []byte{0x03, 0xe8}, c.client)
+	c.closed = true
+	c.mu.Unlock()
+
+	return c.conn.Close()
+}
+
+var errClosed = errors.New("websocket connection is closed")
+
+func (c *Conn) write(opcode byte, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errClosed
+	}
+	err := writeFrame(c.conn, opcode, data, c.client)
+	if opcode == opClose {
+		c.closed = true
+	}
+	return err
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if len(u.Port()) > 0 {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
@@ -0,0 +1,164 @@
+package audioconnector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestHeaderRate(t *testing.T) {
+	header := Header{"content-type": "audio/l16;rate=8000"}
+	if header.Rate() != 8000 {
+		t.Fatalf("Unexpected rate: %d", header.Rate())
+	}
+	if (Header{}).Rate() != 16000 {
+		t.Fatalf("Expected the default rate")
+	}
+}
+
+func TestReceiveAudio(t *testing.T) {
+	received := make(chan []int16, 2)
+	headers := make(chan Header, 1)
+	server := httptest.NewServer(Handler(func(c *Conn) {
+		headers <- c.Header
+		for {
+			samples, err := c.ReadAudio()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- samples
+		}
+	}))
+	defer server.Close()
+
+	c, err := Dial(wsURL(server), Header{
+		"content-type": "audio/l16;rate=16000",
+		"language":     "en",
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	if err = c.WriteJSON(map[string]string{"event": "ignored"}); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	frame := []int16{0, 1, -1, 32767, -32768}
+	if err = c.WriteAudio(frame); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	c.Close()
+
+	header := <-headers
+	if header["language"] != "en" || header.Rate() != 16000 {
+		t.Fatalf("Unexpected header: %v", header)
+	}
+	samples := <-received
+	if len(samples) != len(frame) {
+		t.Fatalf("Unexpected samples: %v", samples)
+	}
+	for i := range frame {
+		if samples[i] != frame[i] {
+			t.Fatalf("Unexpected samples: %v, expected %v", samples, frame)
+		}
+	}
+	if _, ok := <-received; ok {
+		t.Fatalf("Expected the connection to be closed")
+	}
+}
+
+func TestBidirectional(t *testing.T) {
+	server := httptest.NewServer(Handler(func(c *Conn) {
+		for {
+			samples, err := c.ReadAudio()
+			if err != nil {
+				return
+			}
+			c.WriteAudio(samples)
+		}
+	}))
+	defer server.Close()
+
+	c, err := Dial(wsURL(server), Header{"content-type": "audio/l16;rate=8000"})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	defer c.Close()
+
+	// a large frame uses the 16 bit extended length
+	frame := make([]int16, 320)
+	for i := range frame {
+		frame[i] = int16(i)
+	}
+	c.WriteAudio(frame)
+	samples, err := c.ReadAudio()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if len(samples) != len(frame) || samples[319] != 319 {
+		t.Fatalf("Unexpected echo: %d samples", len(samples))
+	}
+}
+
+func TestFragmentsAndPing(t *testing.T) {
+	messages := make(chan []byte, 1)
+	server := httptest.NewServer(Handler(func(c *Conn) {
+		_, data, err := c.ReadMessage()
+		if err == nil {
+			messages <- data
+		}
+	}))
+	defer server.Close()
+
+	c, err := Dial(wsURL(server), Header{})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	defer c.Close()
+
+	// a binary message in two fragments with a ping in between
+	c.conn.Write(maskedFrame(opBinary, false, []byte{1, 2}))
+	c.conn.Write(maskedFrame(opPing, true, []byte("ping")))
+	c.conn.Write(maskedFrame(opContinuation, true, []byte{3}))
+
+	opcode, data, err := c.ReadMessage()
+	if err != io.EOF {
+		t.Fatalf("Expected the server to close the connection: %d %v %v",
+			opcode, data, err)
+	}
+	if data := <-messages; string(data) != "\x01\x02\x03" {
+		t.Fatalf("Unexpected message: %v", data)
+	}
+}
+
+func TestUpgradeFails(t *testing.T) {
+	server := httptest.NewServer(Handler(func(c *Conn) {}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Unexpected status code: %d", res.StatusCode)
+	}
+}
+
+func maskedFrame(opcode byte, fin bool, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^byte(i%4+1))
+	}
+	return frame
+}
@@ -0,0 +1,107 @@
+package opentok
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket rate limiter. It holds up to
+// burst tokens and gets rate tokens per second
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) advance(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// allow takes a token if there is one available
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.advance(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// take takes a token if there is one available. Otherwise it
+// returns how long it takes for a token to become available
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.advance(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// empty removes all the tokens from the bucket
+func (b *tokenBucket) empty(now time.Time) {
+	b.advance(now)
+	b.tokens = 0
+}
+
+// full tells whether the bucket has refilled completely, so it
+// can be discarded and created again without any difference
+func (b *tokenBucket) full(now time.Time) bool {
+	b.advance(now)
+	return b.tokens >= b.burst
+}
+
+// keyedLimiter keeps a token bucket for every key, e.g. for
+// every user
+type keyedLimiter struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newKeyedLimiter(rate float64, burst int) *keyedLimiter {
+	return &keyedLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *keyedLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// discard the buckets that are full before adding new
+		// ones, so idle keys do not pile up
+		if len(l.buckets) >= 1024 {
+			for k, other := range l.buckets {
+				if other.full(now) {
+					delete(l.buckets, k)
+				}
+			}
+		}
+		b = newTokenBucket(l.rate, l.burst, now)
+		l.buckets[key] = b
+	}
+	return b.allow(now)
+}
@@ -0,0 +1,96 @@
+package opentok
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ArchiveResult is the outcome of a bulk operation for one
+// archive. Archive is only set by ArchiveGetMany
+type ArchiveResult struct {
+	ID      string
+	Archive *Archive
+	Err     error
+}
+
+// ArchiveResults holds the outcome of a bulk operation in the
+// same order as the archive ids it was given
+type ArchiveResults []ArchiveResult
+
+// Failed returns the results with an error
+func (r ArchiveResults) Failed() ArchiveResults {
+	var failed ArchiveResults
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns an error that summarizes the failures, or nil if
+// the operation succeeded for every archive
+func (r ArchiveResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(failed))
+	for _, res := range failed {
+		messages = append(messages, fmt.Sprintf("%s: %s", res.ID, res.Err))
+	}
+	return fmt.Errorf("%d of %d archives failed: %s", len(failed), len(r),
+		strings.Join(messages, "; "))
+}
+
+// ArchiveStopMany stops the archives with up to concurrency
+// requests at the same time. Once ctx is done no more requests
+// are made, and the archives left have the context error
+func (ot *OpenTok) ArchiveStopMany(ctx context.Context, archiveIDs []string,
+	concurrency int) ArchiveResults {
+
+	return ot.archiveMany(ctx, archiveIDs, concurrency, func(res *ArchiveResult) {
+		res.Err = ot.ArchiveStop(res.ID)
+	})
+}
+
+// ArchiveDeleteMany deletes the archives with up to concurrency
+// requests at the same time. Once ctx is done no more requests
+// are made, and the archives left have the context error
+func (ot *OpenTok) ArchiveDeleteMany(ctx context.Context, archiveIDs []string,
+	concurrency int) ArchiveResults {
+
+	return ot.archiveMany(ctx, archiveIDs, concurrency, func(res *ArchiveResult) {
+		res.Err = ot.ArchiveDelete(res.ID)
+	})
+}
+
+// ArchiveGetMany retrieves the archives with up to concurrency
+// requests at the same time. Once ctx is done no more requests
+// are made, and the archives left have the context error
+func (ot *OpenTok) ArchiveGetMany(ctx context.Context, archiveIDs []string,
+	concurrency int) ArchiveResults {
+
+	return ot.archiveMany(ctx, archiveIDs, concurrency, func(res *ArchiveResult) {
+		res.Archive, res.Err = ot.ArchiveGet(res.ID)
+	})
+}
+
+func (ot *OpenTok) archiveMany(ctx context.Context, archiveIDs []string,
+	concurrency int, fn func(res *ArchiveResult)) ArchiveResults {
+
+	results := make(ArchiveResults, len(archiveIDs))
+	for i, id := range archiveIDs {
+		results[i].ID = id
+	}
+
+	skipped := parallel(ctx, concurrency, len(results), func(i int) {
+		fn(&results[i])
+	})
+	for _, i := range skipped {
+		results[i].Err = ctx.Err()
+	}
+	return results
+}
@@ -0,0 +1,122 @@
+package opentok
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestArchiveStopMany(t *testing.T) {
+	client := helpers.NewClient()
+	ids := []string{"archive0", "archive1", "archive2", "archive3"}
+	for _, id := range ids[:3] {
+		req := helpers.Archive().RequestStop(apiKey, id).
+			AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+		client.Add(req, helpers.Archive().ValidResponseEmpty())
+	}
+	client.SetDefaultResponse(helpers.NewResponse(404))
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	results := ot.ArchiveStopMany(context.Background(), ids, 2)
+
+	if len(results) != len(ids) {
+		t.Fatalf("Expected a result for every archive: %d", len(results))
+	}
+	for i, res := range results {
+		if res.ID != ids[i] {
+			t.Fatalf("Results should keep the order: %s, expected %s",
+				res.ID, ids[i])
+		}
+	}
+	failed := results.Failed()
+	if len(failed) != 1 || failed[0].ID != "archive3" {
+		t.Fatalf("Expected archive3 to fail: %v", failed)
+	}
+	if results.Err() == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestArchiveGetMany(t *testing.T) {
+	req := helpers.Archive().RequestGet(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Archive().ValidResponseWithArchive(helpers.Archive().DefaultParams())
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	results := ot.ArchiveGetMany(context.Background(), []string{archiveID}, 4)
+
+	if err := results.Err(); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if results[0].Archive == nil || results[0].Archive.ID != archiveID {
+		t.Fatalf("Unexpected archive: %v", results[0].Archive)
+	}
+}
+
+func TestArchiveDeleteManyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	results := ot.ArchiveDeleteMany(ctx, []string{"archive0", "archive1"}, 1)
+
+	for _, res := range results {
+		if res.Err != context.Canceled {
+			t.Fatalf("Expected the context error: %v", res.Err)
+		}
+	}
+}
+
+func TestParallelConcurrency(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+	)
+
+	skipped := parallel(context.Background(), 3, 20, func(i int) {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+
+	if len(skipped) != 0 {
+		t.Fatalf("No index should be skipped: %v", skipped)
+	}
+	if max > 3 {
+		t.Fatalf("Expected at most 3 calls at the same time: %d", max)
+	}
+}
+
+func TestParallelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls []int
+
+	skipped := parallel(ctx, 1, 5, func(i int) {
+		calls = append(calls, i)
+		if i == 1 {
+			cancel()
+		}
+	})
+
+	if len(calls)+len(skipped) != 5 {
+		t.Fatalf("Every index should be called or skipped: %v %v", calls, skipped)
+	}
+	if len(skipped) < 3 {
+		t.Fatalf("Expected at least 3 indexes to be skipped: %v", skipped)
+	}
+}
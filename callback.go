@@ -0,0 +1,242 @@
+package opentok
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCallbackSignature is returned when a callback is not
+	// signed or its signature or payload hash do not match
+	ErrCallbackSignature = errors.New("invalid callback signature")
+
+	// ErrCallbackStale is returned when a callback was signed
+	// outside of the window of the verifier
+	ErrCallbackStale = errors.New("stale callback")
+
+	// ErrCallbackReplayed is returned when a callback has already
+	// been accepted
+	ErrCallbackReplayed = errors.New("replayed callback")
+)
+
+// maxCallbackSize is the largest callback body that is read
+const maxCallbackSize = 1 << 20
+
+// NonceStore remembers the nonces of the callbacks accepted by a
+// CallbackVerifier. Implementations must be safe for concurrent
+// use, and must be shared by all the instances of a service so a
+// callback cannot be replayed against another instance
+type NonceStore interface {
+	// Seen records nonce for ttl and tells whether it had already
+	// been recorded. ttl is relative, so the store measures it with
+	// its own clock
+	Seen(nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore that keeps the nonces in
+// memory until they expire
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// Seen records nonce for ttl and tells whether it had already
+// been recorded
+func (s *MemoryNonceStore) Seen(nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if until, ok := s.nonces[nonce]; ok && now.Before(until) {
+		return true, nil
+	}
+	if len(s.nonces) >= 1024 {
+		for n, until := range s.nonces {
+			if !now.Before(until) {
+				delete(s.nonces, n)
+			}
+		}
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return false, nil
+}
+
+// callbackClaims are the claims of the JWT that the platform
+// sends in the Authorization header of the callbacks
+type callbackClaims struct {
+	IssuedAt    int64  `json:"iat"`
+	ID          string `json:"jti"`
+	PayloadHash string `json:"payload_hash"`
+}
+
+// CallbackVerifier checks that the callbacks come from the OpenTok
+// platform. Every callback carries a JWT signed with HS256 with
+// the project secret, which holds the SHA-256 hash of the body,
+// the time it was signed and a unique id. Callbacks signed outside
+// of the window or whose id has already been seen are rejected
+type CallbackVerifier struct {
+	secret string
+	nonces NonceStore
+	clock  Clock
+	window time.Duration
+}
+
+// NewCallbackVerifier creates a CallbackVerifier for the project
+// with the given secret. If nonces is nil, the nonces are kept
+// in a MemoryNonceStore
+func NewCallbackVerifier(apiSecret string, nonces NonceStore) *CallbackVerifier {
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	return &CallbackVerifier{
+		secret: apiSecret,
+		nonces: nonces,
+		clock:  systemClock{},
+		window: 5 * time.Minute,
+	}
+}
+
+// SetWindow sets how old, or how far in the future to allow for
+// clock skew, a callback can be. It is 5 minutes by default
+func (v *CallbackVerifier) SetWindow(d time.Duration) {
+	v.window = d
+}
+
+// SetClock replaces the clock used to check the age of the
+// callbacks
+func (v *CallbackVerifier) SetClock(c Clock) {
+	v.clock = c
+}
+
+// Verify checks the signature of r and returns its body. The
+// body of r is replaced so it can be read again
+func (v *CallbackVerifier) Verify(r *http.Request) ([]byte, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, ErrCallbackSignature
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxCallbackSize+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxCallbackSize {
+			return nil, fmt.Errorf("callback body is larger than %d bytes",
+				maxCallbackSize)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := v.VerifyToken(strings.TrimPrefix(auth, "Bearer "), body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// VerifyToken checks that token is a valid signature of body
+func (v *CallbackVerifier) VerifyToken(token string, body []byte) error {
+	claims, err := v.parse(token)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(body)
+	expected := hex.EncodeToString(hash[:])
+	if !hmac.Equal([]byte(strings.ToLower(claims.PayloadHash)), []byte(expected)) {
+		return ErrCallbackSignature
+	}
+
+	now := v.clock.Now()
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	if claims.IssuedAt == 0 || issuedAt.Before(now.Add(-v.window)) ||
+		issuedAt.After(now.Add(v.window)) {
+		return ErrCallbackStale
+	}
+
+	if len(claims.ID) == 0 {
+		return ErrCallbackSignature
+	}
+	// the nonce is kept until the callback becomes stale on the
+	// clock of the verifier, which may differ from the store's
+	seen, err := v.nonces.Seen(claims.ID, issuedAt.Add(v.window).Sub(now))
+	if err != nil {
+		return err
+	}
+	if seen {
+		return ErrCallbackReplayed
+	}
+	return nil
+}
+
+// parse checks the HS256 signature of token and returns its claims
+func (v *CallbackVerifier) parse(token string) (*callbackClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrCallbackSignature
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrCallbackSignature
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrCallbackSignature
+	}
+	mac := hmac.New(sha256.New, []byte(v.secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrCallbackSignature
+	}
+
+	var claims callbackClaims
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrCallbackSignature
+	}
+	return &claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Handler returns an http.Handler that calls next only with the
+// callbacks that pass the verification. The others get a 401
+func (v *CallbackVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			writeJSONError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
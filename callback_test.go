@@ -0,0 +1,135 @@
+package opentok
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+const callbackBody = `{"id":"archiveId","status":"available"}`
+
+func newTestVerifier(now time.Time) *CallbackVerifier {
+	v := NewCallbackVerifier(apiSecret, nil)
+	v.SetClock(ClockFunc(func() time.Time { return now }))
+	return v
+}
+
+func TestCallbackVerify(t *testing.T) {
+	now := time.Now()
+	v := newTestVerifier(now)
+
+	req := helpers.Callback().Request(apiSecret, callbackBody, now.Unix(), "nonce1")
+	body, err := v.Verify(req)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if string(body) != callbackBody {
+		t.Fatalf("Unexpected body: %s", body)
+	}
+	// the body can be read again by the handler
+	again, _ := ioutil.ReadAll(req.Body)
+	if string(again) != callbackBody {
+		t.Fatalf("Expected the body to be restored: %s", again)
+	}
+}
+
+func TestCallbackVerifyFails(t *testing.T) {
+	now := time.Now()
+	v := newTestVerifier(now)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		err  error
+	}{
+		{"wrong secret",
+			helpers.Callback().Request("otherSecret", callbackBody, now.Unix(), "a"),
+			ErrCallbackSignature},
+		{"stale",
+			helpers.Callback().Request(apiSecret, callbackBody, now.Unix()-3600, "b"),
+			ErrCallbackStale},
+		{"future",
+			helpers.Callback().Request(apiSecret, callbackBody, now.Unix()+3600, "c"),
+			ErrCallbackStale},
+	}
+
+	// a body that does not match the payload hash
+	forged := helpers.Callback().Request(apiSecret, callbackBody, now.Unix(), "d")
+	forged.Body = ioutil.NopCloser(strings.NewReader(`{"id":"other","status":"available"}`))
+	tests = append(tests, struct {
+		name string
+		req  *http.Request
+		err  error
+	}{"forged body", forged, ErrCallbackSignature})
+
+	// a token with the none algorithm
+	unsigned := helpers.Callback().Request(apiSecret, callbackBody, now.Unix(), "e")
+	unsigned.Header.Set("Authorization",
+		"Bearer eyJhbGciOiJub25lIn0.eyJpYXQiOjE0MzU0ODQ4OTB9.")
+	tests = append(tests, struct {
+		name string
+		req  *http.Request
+		err  error
+	}{"none algorithm", unsigned, ErrCallbackSignature})
+
+	for _, test := range tests {
+		if _, err := v.Verify(test.req); err != test.err {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestCallbackReplayed(t *testing.T) {
+	now := time.Now()
+	v := newTestVerifier(now)
+
+	if _, err := v.Verify(helpers.Callback().Request(apiSecret, callbackBody,
+		now.Unix(), "nonce")); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if _, err := v.Verify(helpers.Callback().Request(apiSecret, callbackBody,
+		now.Unix(), "nonce")); err != ErrCallbackReplayed {
+		t.Fatalf("Expected ErrCallbackReplayed: %v", err)
+	}
+}
+
+func TestCallbackReplayedOtherClock(t *testing.T) {
+	// the clock of the verifier is behind the clock of the store
+	now := time.Now().Add(-time.Hour)
+	v := newTestVerifier(now)
+
+	for i, expected := range []error{nil, ErrCallbackReplayed} {
+		if _, err := v.Verify(helpers.Callback().Request(apiSecret, callbackBody,
+			now.Unix(), "nonce")); err != expected {
+			t.Fatalf("Unexpected error of call %d: %v", i, err)
+		}
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	now := time.Now()
+	v := newTestVerifier(now)
+	called := 0
+	handler := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, helpers.Callback().Request(apiSecret, callbackBody,
+		now.Unix(), "nonce"))
+	if w.Code != http.StatusOK || called != 1 {
+		t.Fatalf("Expected the callback to be accepted: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/callback", strings.NewReader(callbackBody))
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || called != 1 {
+		t.Fatalf("Expected the callback to be rejected: %d", w.Code)
+	}
+}
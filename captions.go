@@ -0,0 +1,34 @@
+package opentok
+
+// CaptionOptions are the settings used to start live captions
+type CaptionOptions struct {
+	// LanguageCode of the speech, en-US if it is empty
+	LanguageCode string
+
+	// MaxDuration is the maximum time in seconds that the session
+	// is captioned, between 300 and 14400. The platform default
+	// is 14400
+	MaxDuration int
+
+	// PartialCaptions sends captions while the speech is being
+	// recognized instead of waiting for complete sentences
+	PartialCaptions bool
+
+	// StatusCallbackURL receives the status changes of the
+	// captions
+	StatusCallbackURL string
+}
+
+// jsonCaptionsRequest is the body of the captions request
+type jsonCaptionsRequest struct {
+	SessionID         string `json:"sessionId"`
+	Token             string `json:"token"`
+	LanguageCode      string `json:"languageCode"`
+	MaxDuration       int    `json:"maxDuration,omitempty"`
+	PartialCaptions   bool   `json:"partialCaptions"`
+	StatusCallbackURL string `json:"statusCallbackUrl,omitempty"`
+}
+
+type jsonCaptionsResponse struct {
+	CaptionsID string `json:"captionsId"`
+}
@@ -0,0 +1,101 @@
+package opentok
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"archiveId","status":"available","partnerId":%d}`, apiKey)
+	}))
+	defer server.Close()
+
+	recorder := helpers.NewRecordingTransport(nil)
+	ot := newOpenTokWithURL(apiKey, apiSecret, server.URL)
+	ot.client = &http.Client{Transport: recorder}
+	if _, err := ot.ArchiveGet(archiveID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "archive.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	cassette, err := helpers.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if len(cassette.Interactions) != 1 {
+		t.Fatalf("Unexpected interactions: %+v", cassette.Interactions)
+	}
+	if auth := cassette.Interactions[0].Request.Header.Get("X-TB-PARTNER-AUTH"); auth != "REDACTED" {
+		t.Fatalf("Expected the credentials to be redacted: %s", auth)
+	}
+
+	replay := helpers.NewReplayClient(cassette, helpers.MatchAll)
+	ot = newOpenTokWithURL(apiKey, apiSecret, server.URL)
+	ot.client = replay
+	archive, err := ot.ArchiveGet(archiveID)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if archive.ID != "archiveId" || archive.Status != "available" {
+		t.Fatalf("Unexpected archive: %+v", archive)
+	}
+	if replay.Remaining() != 0 {
+		t.Fatalf("Expected the interaction to be used")
+	}
+	if _, err = ot.ArchiveGet(archiveID); err == nil {
+		t.Fatalf("Expected every interaction to be served once")
+	}
+}
+
+func TestReplaySemanticMatching(t *testing.T) {
+	cassette := &helpers.Cassette{Interactions: []helpers.Interaction{{
+		Request: helpers.RecordedRequest{
+			Method: "POST",
+			URL:    fmt.Sprintf("https://api.opentok.com/v2/partner/%d/archive?unused=1", apiKey),
+			Body: `{"sessionId": "sessionId", "outputMode": "composed",
+				"name": "", "hasVideo": true, "hasAudio": true}`,
+		},
+		Response: helpers.RecordedResponse{
+			StatusCode: 200,
+			Body:       `{"id":"archiveId","status":"started"}`,
+		},
+	}}}
+
+	// the query is ignored, the JSON keys are in another order
+	match := helpers.MatchMethod | helpers.MatchPath | helpers.MatchBody
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewReplayClient(cassette, match))
+	archive, err := ot.ArchiveStart(sessionID, nil)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if archive.ID != "archiveId" {
+		t.Fatalf("Unexpected archive: %+v", archive)
+	}
+}
+
+func TestClientJSONKeyOrder(t *testing.T) {
+	url := fmt.Sprintf("https://api.opentok.com/v2/project/%d/archive/storage", apiKey)
+	req := helpers.NewRequestWithBody("PUT", url,
+		`{"type":"s3","fallback":"none","config":{"secretKey":"s","bucket":"b","accessKey":"a"}}`)
+	client := helpers.NewClient().
+		Add(req, helpers.Storage().ValidResponseEmpty())
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	err := ot.SetArchiveStorage(StorageConfig{
+		Type: S3,
+		S3:   &S3Config{AccessKey: "a", Bucket: "b", SecretKey: "s"},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
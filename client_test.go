@@ -0,0 +1,105 @@
+package opentok
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestClientConcurrent(t *testing.T) {
+	client := helpers.NewClient()
+	stops := make([]*helpers.Expectation, 4)
+	for i := range stops {
+		req := helpers.Archive().RequestStop(apiKey, archiveIDs(4)[i]).
+			AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+		stops[i] = client.Expect(req, helpers.Archive().ValidResponseEmpty()).Times(5)
+	}
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	var wg sync.WaitGroup
+	for _, id := range archiveIDs(4) {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				if err := ot.ArchiveStop(id); err != nil {
+					t.Errorf("Expected err to be nil: %s", err)
+				}
+			}(id)
+		}
+	}
+	wg.Wait()
+
+	client.AssertExpectationsMet(t)
+	if client.Calls() != 20 || stops[0].Calls() != 5 {
+		t.Fatalf("Unexpected calls: %d", client.Calls())
+	}
+}
+
+func TestClientTimes(t *testing.T) {
+	req := helpers.Archive().RequestDelete(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	client := helpers.NewClient()
+	client.Expect(req, helpers.Archive().ValidResponseEmpty()).Times(1)
+	client.Expect(req, helpers.NewResponse(404)).Times(1)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.ArchiveDelete(archiveID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.ArchiveDelete(archiveID); err == nil {
+		t.Fatalf("Expected the second expectation to be used")
+	}
+	if err := ot.ArchiveDelete(archiveID); err == nil {
+		t.Fatalf("Expected the expectations to be exhausted")
+	}
+}
+
+func TestClientClosestDiff(t *testing.T) {
+	req := helpers.Archive().RequestStop(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", "wrong")
+	client := helpers.NewClient().
+		Add(req, helpers.Archive().ValidResponseEmpty())
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	err := ot.ArchiveStop(archiveID)
+	if err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if !strings.Contains(err.Error(), "header X-Tb-Partner-Auth") ||
+		strings.Contains(err.Error(), "url:") {
+		t.Fatalf("Expected the diff to show the header only: %s", err)
+	}
+}
+
+func TestClientOrdered(t *testing.T) {
+	client := helpers.NewClient()
+	client.SetOrdered(true)
+	for _, id := range archiveIDs(2) {
+		req := helpers.Archive().RequestStop(apiKey, id).
+			AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+		client.Expect(req, helpers.Archive().ValidResponseEmpty()).Times(1)
+	}
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.ArchiveStop("archive1"); err == nil {
+		t.Fatalf("Expected the requests to be in order")
+	}
+	if err := ot.ArchiveStop("archive0"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.ArchiveStop("archive1"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func archiveIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("archive%d", i)
+	}
+	return ids
+}
@@ -0,0 +1,68 @@
+package opentok
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+)
+
+// Clock tells the current time. It is used to set the creation
+// and expiration times of the tokens
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to use a function as a Clock
+type ClockFunc func() time.Time
+
+// Now calls f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NonceSource generates the nonce of every token. Nonces must
+// be between 0 and 999999
+type NonceSource interface {
+	Nonce() (int64, error)
+}
+
+// NonceSourceFunc is an adapter to use a function as a
+// NonceSource
+type NonceSourceFunc func() (int64, error)
+
+// Nonce calls f()
+func (f NonceSourceFunc) Nonce() (int64, error) {
+	return f()
+}
+
+var maxNonce = big.NewInt(1000000)
+
+// cryptoNonces generates unpredictable nonces with crypto/rand
+type cryptoNonces struct{}
+
+func (cryptoNonces) Nonce() (int64, error) {
+	n, err := rand.Int(rand.Reader, maxNonce)
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64(), nil
+}
+
+// SetClock replaces the clock used to generate the tokens. It
+// is useful to generate the same token in tests
+func (ot *OpenTok) SetClock(c Clock) {
+	ot.clock = c
+}
+
+// SetNonceSource replaces the source of the token nonces, which
+// by default are generated with crypto/rand. It is useful to
+// generate the same token in tests
+func (ot *OpenTok) SetNonceSource(n NonceSource) {
+	ot.nonces = n
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk"
+)
+
+// archiveIDArg parses the flags of commands that take an
+// archive id as their only argument
+func archiveIDArg(fs *flag.FlagSet, args []string) (string, error) {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("usage: %s [flags] <archiveId>", fs.Name())
+	}
+	return fs.Arg(0), nil
+}
+
+func archiveStart(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs      = flag.NewFlagSet("archive start", flag.ExitOnError)
+		name    = fs.String("name", "", "name of the archive")
+		noAudio = fs.Bool("no-audio", false, "do not record audio")
+		noVideo = fs.Bool("no-video", false, "do not record video")
+		output  = fs.String("output-mode", "composed",
+			"output mode: composed or individual")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: archive start [flags] <sessionId>")
+	}
+
+	var mode opentok.OutputMode
+	switch *output {
+	case "composed":
+		mode = opentok.Composed
+	case "individual":
+		mode = opentok.Individual
+	default:
+		return fmt.Errorf("unknown output mode: %q", *output)
+	}
+
+	a, err := ot.ArchiveStart(fs.Arg(0), &opentok.ArchiveProps{
+		HasAudio:   !*noAudio,
+		HasVideo:   !*noVideo,
+		Name:       *name,
+		OutputMode: mode,
+	})
+	if err != nil {
+		return err
+	}
+	return out.archive(a)
+}
+
+func archiveStop(ot *opentok.OpenTok, out *printer, args []string) error {
+	id, err := archiveIDArg(flag.NewFlagSet("archive stop", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	if err = ot.ArchiveStop(id); err != nil {
+		return err
+	}
+	return archiveGet(ot, out, []string{id})
+}
+
+func archiveGet(ot *opentok.OpenTok, out *printer, args []string) error {
+	id, err := archiveIDArg(flag.NewFlagSet("archive get", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	a, err := ot.ArchiveGet(id)
+	if err != nil {
+		return err
+	}
+	return out.archive(a)
+}
+
+func archiveDelete(ot *opentok.OpenTok, out *printer, args []string) error {
+	id, err := archiveIDArg(flag.NewFlagSet("archive delete", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	if err = ot.ArchiveDelete(id); err != nil {
+		return err
+	}
+	return out.print(map[string]string{"id": id, "status": "deleted"},
+		[]string{"ID", "STATUS"}, [][]string{{id, "deleted"}})
+}
+
+func archiveList(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs     = flag.NewFlagSet("archive list", flag.ExitOnError)
+		count  = fs.Int("count", 0, "maximum number of archives, 0 for the server limit")
+		offset = fs.Int("offset", 0, "number of archives to skip")
+	)
+	fs.Parse(args)
+
+	list, err := ot.ArchiveList(*count, *offset)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(list.Archives))
+	for i := range list.Archives {
+		rows = append(rows, archiveRow(&list.Archives[i]))
+	}
+	return out.print(list, archiveHeader, rows)
+}
+
+func archiveWait(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs       = flag.NewFlagSet("archive wait", flag.ExitOnError)
+		interval = fs.Duration("interval", 5*time.Second, "time between checks")
+		timeout  = fs.Duration("timeout", 30*time.Minute, "maximum time to wait")
+	)
+	id, err := archiveIDArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(*timeout)
+	for {
+		a, err := ot.ArchiveGet(id)
+		if err != nil {
+			return err
+		}
+
+		switch a.Status {
+		case "available", "uploaded":
+			return out.archive(a)
+		case "failed", "expired", "deleted":
+			out.archive(a)
+			return fmt.Errorf("archive %s is %s", id, a.Status)
+		}
+
+		if time.Now().Add(*interval).After(deadline) {
+			return fmt.Errorf("archive %s is still %s after %s",
+				id, a.Status, *timeout)
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func archiveDownload(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs   = flag.NewFlagSet("archive download", flag.ExitOnError)
+		file = fs.String("o", "", "output file, <archiveId>.mp4 or "+
+			"<archiveId>.zip by default")
+	)
+	id, err := archiveIDArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	a, err := ot.ArchiveGet(id)
+	if err != nil {
+		return err
+	}
+	if a.Status != "available" || len(a.URL) == 0 {
+		return fmt.Errorf("archive %s is %s and cannot be downloaded",
+			id, a.Status)
+	}
+
+	res, err := http.Get(a.URL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed with status code %d", res.StatusCode)
+	}
+
+	path := *file
+	if len(path) == 0 {
+		path = id + ".mp4"
+		if res.Header.Get("Content-Type") == "application/zip" {
+			path = id + ".zip"
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, res.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return out.print(map[string]interface{}{"id": id, "file": path, "bytes": n},
+		[]string{"ID", "FILE", "BYTES"},
+		[][]string{{id, path, fmt.Sprintf("%d", n)}})
+}
@@ -1,44 +1,126 @@
+// Command cmd is a command line utility to manage OpenTok sessions,
+// tokens and archives without writing Go code.
+//
+// The credentials are read from API_KEY and API_SECRET or from a
+// profile in the config file (~/.opentok.json by default):
+//
+//	{"default": {"apiKey": 123456, "apiSecret": "API_SECRET"}}
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/eauge/opentok-go-sdk"
 )
 
-func main() {
+const usage = `Usage: cmd [flags] <command> [command flags]
 
-	apiKeyString := os.Getenv("API_KEY")
-	if len(apiKeyString) == 0 {
-		fmt.Println("API_KEY must be set in order to use this utility")
-		return
+Commands:
+  session create    creates a new session
+  token             generates a token for a session
+  token decode      shows the values a token was generated with
+  archive start     starts archiving a session
+  archive stop      stops an archive
+  archive get       retrieves an archive
+  archive list      lists the archives of the project
+  archive delete    deletes an archive
+  archive wait      waits until an archive is available or uploaded
+  archive download  downloads an available archive
+
+Run 'cmd <command> -h' to see the flags of a command.
+
+Flags:
+`
+
+type command func(ot *opentok.OpenTok, out *printer, args []string) error
+
+var commands = map[string]command{
+	"session create":   sessionCreate,
+	"token":            tokenCreate,
+	"token decode":     tokenDecode,
+	"archive start":    archiveStart,
+	"archive stop":     archiveStop,
+	"archive get":      archiveGet,
+	"archive list":     archiveList,
+	"archive delete":   archiveDelete,
+	"archive wait":     archiveWait,
+	"archive download": archiveDownload,
+}
+
+func main() {
+	var (
+		configPath = flag.String("config", defaultConfigPath(),
+			"config file with the profiles")
+		profile = flag.String("profile", os.Getenv("OPENTOK_PROFILE"),
+			"profile to use from the config file")
+		format  = flag.String("output", "table", "output format: table or json")
+		version = flag.Bool("version", false, "print the version of the SDK")
+		apiURL  = flag.String("api-url", os.Getenv("OPENTOK_API_URL"),
+			"url of the OpenTok platform, e.g. a regional endpoint")
+	)
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
 	}
-	apiKey, err := strconv.Atoi(apiKeyString)
-	if err != nil {
-		fmt.Println("API_KEY must be an int: ", err)
+	flag.Parse()
+
+	if *version {
+		fmt.Println("opentok-go-sdk", opentok.Version)
 		return
 	}
-	apiSecret := os.Getenv("API_SECRET")
-	if len(apiSecret) == 0 {
-		fmt.Println("API_SECRET must be set in order to use this utility")
-		return
+
+	name, cmd, args := findCommand(flag.Args())
+	if cmd == nil {
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	ot := opentok.New(apiKey, apiSecret)
-	s, err := ot.Session(nil)
+	out, err := newPrinter(os.Stdout, *format)
 	if err != nil {
-		fmt.Println("Session could not be created: err: ", err)
-		return
+		fail(err)
 	}
 
-	fmt.Println("Session created: ", s)
+	// token decode does not need any credentials
+	var ot *opentok.OpenTok
+	if name != "token decode" {
+		c, err := loadCredential(*configPath, *profile)
+		if err != nil {
+			fail(err)
+		}
+		ot = opentok.New(c.APIKey, c.APISecret)
+		ot.SetUserAgentSuffix("opentok-cmd")
+		if len(*apiURL) > 0 {
+			if err := ot.SetAPIURL(*apiURL); err != nil {
+				fail(err)
+			}
+		}
+	}
 
-	t, err := ot.Token(s, nil)
-	if err != nil {
-		fmt.Println("Token could not be created: err: ", err)
-		return
+	if err := cmd(ot, out, args); err != nil {
+		fail(err)
 	}
-	fmt.Println("Token created: ", t)
+}
+
+// findCommand matches the longest command name at the
+// beginning of args and returns the remaining arguments
+func findCommand(args []string) (string, command, []string) {
+	if len(args) >= 2 {
+		name := args[0] + " " + args[1]
+		if cmd, ok := commands[name]; ok {
+			return name, cmd, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if cmd, ok := commands[args[0]]; ok {
+			return args[0], cmd, args[1:]
+		}
+	}
+	return "", nil, nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
 }
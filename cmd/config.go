@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/eauge/opentok-go-sdk"
+)
+
+func defaultConfigPath() string {
+	if path := os.Getenv("OPENTOK_CONFIG"); len(path) > 0 {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".opentok.json"
+	}
+	return filepath.Join(home, ".opentok.json")
+}
+
+// loadCredential reads the credentials from API_KEY and
+// API_SECRET. If they are not set, it reads the profile from
+// the config file, "default" if profile is empty
+func loadCredential(path, profile string) (*opentok.Credential, error) {
+	apiKeyString := os.Getenv("API_KEY")
+	apiSecret := os.Getenv("API_SECRET")
+	if len(profile) == 0 && len(apiKeyString) > 0 && len(apiSecret) > 0 {
+		apiKey, err := strconv.Atoi(apiKeyString)
+		if err != nil {
+			return nil, fmt.Errorf("API_KEY must be an int: %s", err)
+		}
+		return &opentok.Credential{APIKey: apiKey, APISecret: apiSecret}, nil
+	}
+
+	if len(profile) == 0 {
+		profile = "default"
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("API_KEY and API_SECRET must be set or "+
+			"a profile must be added to %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]opentok.Credential
+	if err = json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("%s could not be parsed: %s", path, err)
+	}
+
+	c, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	if c.APIKey == 0 || len(c.APISecret) == 0 {
+		return nil, fmt.Errorf("profile %q needs apiKey and apiSecret", profile)
+	}
+	return &c, nil
+}
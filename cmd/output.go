@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk"
+)
+
+// printer writes the results of the commands either as JSON
+// or as a table for humans
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "json":
+		return &printer{w: w, json: true}, nil
+	case "table":
+		return &printer{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown output format: %q", format)
+}
+
+// print writes v as JSON, or header and rows as a table
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+var archiveHeader = []string{"ID", "NAME", "SESSION", "STATUS",
+	"CREATED", "DURATION", "SIZE"}
+
+func archiveRow(a *opentok.Archive) []string {
+	created := time.Unix(a.CreatedAt/1000, 0).UTC().Format(time.RFC3339)
+	return []string{a.ID, a.Name, a.SessionID, a.Status, created,
+		fmt.Sprintf("%ds", a.Duration), fmt.Sprintf("%d", a.Size)}
+}
+
+func (p *printer) archive(a *opentok.Archive) error {
+	return p.print(a, archiveHeader, [][]string{archiveRow(a)})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk"
+)
+
+func sessionCreate(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs       = flag.NewFlagSet("session create", flag.ExitOnError)
+		location = fs.String("location", "", "IP address used to choose "+
+			"the location of the session")
+		media   = fs.String("media", "routed", "media mode: routed or relayed")
+		archive = fs.String("archive", "manual", "archive mode: manual or always")
+	)
+	fs.Parse(args)
+
+	props := &opentok.SessionProps{Location: *location}
+	switch *media {
+	case "routed":
+		props.MediaMode = opentok.Routed
+	case "relayed":
+		props.MediaMode = opentok.Relayed
+	default:
+		return fmt.Errorf("unknown media mode: %q", *media)
+	}
+	switch *archive {
+	case "manual":
+		props.ArchiveMode = opentok.Manual
+	case "always":
+		props.ArchiveMode = opentok.Always
+	default:
+		return fmt.Errorf("unknown archive mode: %q", *archive)
+	}
+
+	s, err := ot.Session(props)
+	if err != nil {
+		return err
+	}
+	return out.print(s, []string{"SESSION"}, [][]string{{s.ID}})
+}
+
+func tokenCreate(ot *opentok.OpenTok, out *printer, args []string) error {
+	var (
+		fs        = flag.NewFlagSet("token", flag.ExitOnError)
+		sessionID = fs.String("session", "", "session id (required)")
+		role      = fs.String("role", "publisher",
+			"role: publisher, subscriber or moderator")
+		expire = fs.Duration("expire", 24*time.Hour, "time until the token expires")
+		data   = fs.String("data", "", "connection data")
+	)
+	fs.Parse(args)
+
+	if len(*sessionID) == 0 {
+		return fmt.Errorf("-session is required")
+	}
+
+	var r opentok.Role
+	switch *role {
+	case "publisher":
+		r = opentok.Publisher
+	case "subscriber":
+		r = opentok.Subscriber
+	case "moderator":
+		r = opentok.Moderator
+	default:
+		return fmt.Errorf("unknown role: %q", *role)
+	}
+
+	t, err := ot.Token(*sessionID, &opentok.TokenProps{
+		Role:       r,
+		ExpireTime: time.Now().Add(*expire).Unix(),
+		Data:       *data,
+	})
+	if err != nil {
+		return err
+	}
+	return out.print(map[string]string{"token": t.String()},
+		[]string{"TOKEN"}, [][]string{{t.String()}})
+}
+
+func tokenDecode(ot *opentok.OpenTok, out *printer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: token decode <token>")
+	}
+
+	info, err := opentok.DecodeToken(args[0])
+	if err != nil {
+		return err
+	}
+
+	unix := func(t int64) string {
+		return time.Unix(t, 0).UTC().Format(time.RFC3339)
+	}
+	return out.print(info, []string{"FIELD", "VALUE"}, [][]string{
+		{"API key", fmt.Sprintf("%d", info.APIKey)},
+		{"Session", info.SessionID},
+		{"Role", string(info.Role)},
+		{"Created", unix(info.CreateTime)},
+		{"Expires", unix(info.ExpireTime)},
+		{"Nonce", fmt.Sprintf("%d", info.Nonce)},
+		{"Data", info.Data},
+	})
+}
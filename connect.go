@@ -0,0 +1,40 @@
+package opentok
+
+// WebSocketOptions are the settings of the websocket that the
+// Audio Connector opens to stream the audio of a session
+type WebSocketOptions struct {
+	// URI of the websocket server, ws:// or wss://
+	URI string `json:"uri"`
+
+	// Streams are the ids of the streams to send. All the streams
+	// of the session are sent if it is empty
+	Streams []string `json:"streams,omitempty"`
+
+	// Headers are sent in the first message of the websocket
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// AudioRate is the sample rate of the audio, 8000 or 16000.
+	// The platform default is 16000
+	AudioRate int `json:"audioRate,omitempty"`
+
+	// Bidirectional lets the websocket server send audio back
+	// into the session
+	Bidirectional bool `json:"bidirectional,omitempty"`
+}
+
+// AudioConnection is an Audio Connector streaming the audio of a
+// session to a websocket
+type AudioConnection struct {
+	// ID of the Audio Connector call. It's used to disconnect it
+	ID string `json:"id"`
+
+	// ConnectionID is the id of the connection of the Audio
+	// Connector in the session
+	ConnectionID string `json:"connectionId"`
+}
+
+type jsonConnectRequest struct {
+	SessionID string           `json:"sessionId"`
+	Token     string           `json:"token"`
+	WebSocket WebSocketOptions `json:"websocket"`
+}
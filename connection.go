@@ -0,0 +1,29 @@
+package opentok
+
+// Connection is a client connected to an OpenTok session
+type Connection struct {
+	// ID of the connection. It's used to force the client to
+	// disconnect
+	ID string `json:"connectionId"`
+
+	// Unix timestamp in milliseconds when the client connected
+	CreatedAt int64 `json:"createdAt"`
+
+	// State of the connection. The possibilities are:
+	// - `Connecting`: the client is joining the session
+	// - `Connected`: the client has joined the session
+	State string `json:"connectionState"`
+
+	// Data is the connection data of the token used by the
+	// client to connect. Tokens carry TokenProps.Data as is, so
+	// it is the same string as TokenInfo.Data of DecodeToken
+	Data string `json:"data"`
+}
+
+// ConnectionList holds the list of connections of a session
+// retrieved from the opentok service
+type ConnectionList struct {
+	Count       int          `json:"count"`
+	SessionID   string       `json:"sessionId"`
+	Connections []Connection `json:"items"`
+}
@@ -0,0 +1,323 @@
+package opentok
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// endpoint describes an operation of the OpenTok REST API. path is
+// a template whose placeholders are filled with the API key and the
+// arguments of the call, in order. The template is also the
+// endpoint reported to the observers
+type endpoint struct {
+	operation string
+	method    string
+	path      string
+
+	// accept is the content type of the response, JSON if empty
+	accept string
+
+	// idempotent operations are retried with the RetryPolicy
+	idempotent bool
+}
+
+var (
+	sessionCreate = &endpoint{
+		operation: "Session",
+		method:    "POST",
+		path:      "/session/create",
+		accept:    "application/xml",
+	}
+
+	archiveStart = &endpoint{
+		operation: "ArchiveStart",
+		method:    "POST",
+		path:      "/v2/partner/{apiKey}/archive",
+	}
+	archiveStop = &endpoint{
+		operation: "ArchiveStop",
+		method:    "POST",
+		path:      "/v2/partner/{apiKey}/archive/{archiveId}/stop",
+	}
+	archiveGet = &endpoint{
+		operation:  "ArchiveGet",
+		method:     "GET",
+		path:       "/v2/partner/{apiKey}/archive/{archiveId}",
+		idempotent: true,
+	}
+	archiveDelete = &endpoint{
+		operation:  "ArchiveDelete",
+		method:     "DELETE",
+		path:       "/v2/partner/{apiKey}/archive/{archiveId}",
+		idempotent: true,
+	}
+	archiveList = &endpoint{
+		operation:  "ArchiveList",
+		method:     "GET",
+		path:       "/v2/partner/{apiKey}/archive",
+		idempotent: true,
+	}
+
+	archiveStorageSet = &endpoint{
+		operation:  "SetArchiveStorage",
+		method:     "PUT",
+		path:       "/v2/project/{apiKey}/archive/storage",
+		idempotent: true,
+	}
+	archiveStorageGet = &endpoint{
+		operation:  "GetArchiveStorage",
+		method:     "GET",
+		path:       "/v2/project/{apiKey}/archive/storage",
+		idempotent: true,
+	}
+	archiveStorageDelete = &endpoint{
+		operation:  "DeleteArchiveStorage",
+		method:     "DELETE",
+		path:       "/v2/project/{apiKey}/archive/storage",
+		idempotent: true,
+	}
+
+	renderStart = &endpoint{
+		operation: "RenderStart",
+		method:    "POST",
+		path:      "/v2/project/{apiKey}/render",
+	}
+	renderStop = &endpoint{
+		operation:  "RenderStop",
+		method:     "DELETE",
+		path:       "/v2/project/{apiKey}/render/{renderId}",
+		idempotent: true,
+	}
+	renderGet = &endpoint{
+		operation:  "RenderGet",
+		method:     "GET",
+		path:       "/v2/project/{apiKey}/render/{renderId}",
+		idempotent: true,
+	}
+	renderList = &endpoint{
+		operation:  "RenderList",
+		method:     "GET",
+		path:       "/v2/project/{apiKey}/render",
+		idempotent: true,
+	}
+
+	audioConnect = &endpoint{
+		operation: "ConnectAudioToWebSocket",
+		method:    "POST",
+		path:      "/v2/project/{apiKey}/connect",
+	}
+	audioDisconnect = &endpoint{
+		operation: "DisconnectWebSocket",
+		method:    "POST",
+		path:      "/v2/project/{apiKey}/connect/{connectId}/stop",
+	}
+
+	captionsStart = &endpoint{
+		operation: "CaptionsStart",
+		method:    "POST",
+		path:      "/v2/project/{apiKey}/captions",
+	}
+	captionsStop = &endpoint{
+		operation: "CaptionsStop",
+		method:    "POST",
+		path:      "/v2/project/{apiKey}/captions/{captionsId}/stop",
+	}
+
+	connectionList = &endpoint{
+		operation:  "ConnectionList",
+		method:     "GET",
+		path:       "/v2/project/{apiKey}/session/{sessionId}/connection",
+		idempotent: true,
+	}
+	connectionDelete = &endpoint{
+		operation:  "ForceDisconnect",
+		method:     "DELETE",
+		path:       "/v2/project/{apiKey}/session/{sessionId}/connection/{connectionId}",
+		idempotent: true,
+	}
+)
+
+// url fills the placeholders of the path with the API key and
+// args and returns the full URL
+func (e *endpoint) url(apiURL string, apiKey int, args []string, query url.Values) (string, error) {
+	var path strings.Builder
+	rest := e.path
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid endpoint path: %s", e.path)
+		}
+		path.WriteString(rest[:start])
+
+		if name := rest[start+1 : start+end]; name == "apiKey" {
+			path.WriteString(strconv.Itoa(apiKey))
+		} else {
+			if len(args) == 0 {
+				return "", fmt.Errorf("%s: missing %s", e.operation, name)
+			}
+			path.WriteString(url.PathEscape(args[0]))
+			args = args[1:]
+		}
+		rest = rest[start+end+1:]
+	}
+	path.WriteString(rest)
+
+	u := apiURL + path.String()
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u, nil
+}
+
+// pageQuery returns the query of the list operations. count is
+// left to the server if it is 0
+func pageQuery(count, offset int) (url.Values, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("count must be bigger than 0: %d", count)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must be bigger than or equal to 0: %d",
+			offset)
+	}
+
+	query := url.Values{"offset": {strconv.Itoa(offset)}}
+	if count > 0 {
+		query.Set("count", strconv.Itoa(count))
+	}
+	return query, nil
+}
+
+// APIError is returned when the OpenTok platform answers with an
+// error status code
+type APIError struct {
+	Operation  string
+	StatusCode int
+
+	// Message is the body of the response, if any
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("Error: statusCode: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Error: statusCode: %d, message: %s",
+		e.StatusCode, e.Message)
+}
+
+// RetryPolicy sets how the idempotent operations, e.g. ArchiveGet
+// or ArchiveDelete, are retried after a transport error or a 429
+// or 5xx response. Operations that create or stop something are
+// never retried
+type RetryPolicy struct {
+	MaxRetries int
+
+	// Backoff is the wait before the first retry. It doubles
+	// after every retry. 100ms if it is 0
+	Backoff time.Duration
+}
+
+// SetRetryPolicy sets how the failed calls are retried. By
+// default they are not retried. It must be called before the
+// OpenTok object is used
+func (ot *OpenTok) SetRetryPolicy(p RetryPolicy) {
+	if p.Backoff <= 0 {
+		p.Backoff = 100 * time.Millisecond
+	}
+	ot.retry = p
+}
+
+// retryable tells whether a call that failed with err may succeed
+// if it is made again
+func retryable(err error) bool {
+	if err == ErrRateLimited {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// execute makes a call to e. args fill the placeholders of the
+// path after the API key. body is sent as a form if it is a
+// url.Values and as JSON otherwise. The response is decoded into
+// result unless it is nil
+func (ot *OpenTok) execute(e *endpoint, args []string, query url.Values,
+	body, result interface{}) error {
+
+	u, err := e.url(ot.apiURL, ot.APIKey, args, query)
+	if err != nil {
+		return err
+	}
+
+	var (
+		data        []byte
+		contentType string
+	)
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		data = []byte(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		if data, err = json.Marshal(b); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	retries := 0
+	if e.idempotent {
+		retries = ot.retry.MaxRetries
+	}
+	backoff := ot.retry.Backoff
+
+	for attempt := 0; ; attempt++ {
+		var req *http.Request
+		if data != nil {
+			req, err = http.NewRequest(e.method, u, bytes.NewReader(data))
+		} else {
+			req, err = http.NewRequest(e.method, u, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if len(contentType) > 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+		accept := e.accept
+		if len(accept) == 0 {
+			accept = "application/json"
+		}
+		req.Header.Set("Accept", accept)
+		ot.commonHeaders(&req.Header)
+
+		res, err := ot.do(req, e.operation, e.path)
+		if err == nil {
+			defer closeBody(res)
+			if result == nil {
+				return nil
+			}
+			return decodeBody(res, result)
+		}
+		if attempt >= retries || !retryable(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
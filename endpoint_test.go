@@ -0,0 +1,122 @@
+package opentok
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// respondInSequence answers with the given status codes in order
+// and records the requests
+func respondInSequence(statusCodes ...int) (httpClient, *[]*http.Request) {
+	var requests []*http.Request
+	return clientFunc(func(req *http.Request) (*http.Response, error) {
+		statusCode := statusCodes[len(requests)]
+		requests = append(requests, req)
+		return &http.Response{
+			StatusCode: statusCode,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"archiveId"}`)),
+		}, nil
+	}), &requests
+}
+
+func TestEndpointURL(t *testing.T) {
+	u, err := connectionDelete.url("https://api.opentok.com", 123456,
+		[]string{"session/id", "connectionId"}, nil)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	expected := "https://api.opentok.com/v2/project/123456/session/session%2Fid/connection/connectionId"
+	if u != expected {
+		t.Fatalf("Unexpected url: %s", u)
+	}
+
+	if _, err = connectionDelete.url("", 123456, []string{"sessionId"}, nil); err == nil {
+		t.Fatalf("Expected a missing argument to fail")
+	}
+}
+
+func TestExecuteHeaders(t *testing.T) {
+	client, requests := respondInSequence(200)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if _, err := ot.ArchiveStart("sessionId", nil); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	req := (*requests)[0]
+	for name, expected := range map[string]string{
+		"Content-Type":      "application/json",
+		"Accept":            "application/json",
+		"User-Agent":        userAgent,
+		"X-TB-PARTNER-AUTH": ot.partnerAuth,
+	} {
+		if got := req.Header.Get(name); got != expected {
+			t.Fatalf("Unexpected %s header: %q", name, got)
+		}
+	}
+}
+
+func TestExecuteAPIError(t *testing.T) {
+	client, _ := respondWith(404, "application/json", `{"message":"not found"}`)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	_, err := ot.ArchiveGet("archiveId")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an APIError: %v", err)
+	}
+	if apiErr.Operation != "ArchiveGet" || apiErr.StatusCode != 404 ||
+		apiErr.Message != `{"message":"not found"}` {
+		t.Fatalf("Unexpected error: %+v", apiErr)
+	}
+}
+
+func TestExecuteRetries(t *testing.T) {
+	client, requests := respondInSequence(503, 429, 200)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+
+	archive, err := ot.ArchiveGet("archiveId")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if archive.ID != "archiveId" || len(*requests) != 3 {
+		t.Fatalf("Unexpected result after %d requests: %+v", len(*requests), archive)
+	}
+}
+
+func TestExecuteRetriesExhausted(t *testing.T) {
+	client, requests := respondInSequence(500, 500)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRetryPolicy(RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+
+	err := ot.ArchiveDelete("archiveId")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != 500 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Unexpected number of requests: %d", len(*requests))
+	}
+}
+
+func TestExecuteNoRetries(t *testing.T) {
+	// client errors and operations that are not idempotent are
+	// not retried
+	client, requests := respondInSequence(400)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRetryPolicy(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond})
+	if _, err := ot.ArchiveGet("archiveId"); err == nil || len(*requests) != 1 {
+		t.Fatalf("Unexpected result after %d requests: %v", len(*requests), err)
+	}
+
+	client, requests = respondInSequence(503)
+	ot = newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRetryPolicy(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond})
+	if _, err := ot.ArchiveStart("sessionId", nil); err == nil || len(*requests) != 1 {
+		t.Fatalf("Unexpected result after %d requests: %v", len(*requests), err)
+	}
+}
@@ -1,6 +1,9 @@
 package helpers
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 var archiveResponseBody = "{\"createdAt\" : 1384221730555,\n \"duration\" : 60,\n \"hasAudio\" : %t,\n \"hasVideo\" : %t,\n \"id\" : \"%s\",\n \"name\" : \"%s\",\n \"partnerId\" : %d,\n \"reason\" : \"\",\n \"sessionId\" : \"%s\",\n \"size\" : 0,\n \"status\" : \"%s\",\n \"url\" : null}"
 
@@ -129,6 +132,21 @@ func (a *ArchiveHelper) ValidResponseWithArchiveList(count int) *Response {
 	return NewResponseWithBody(200, archiveList)
 }
 
+// ValidResponseWithArchives generates a response that will
+// contain a JSON archive list with the given archives. count
+// is the total number of archives, which can be bigger than
+// the number of archives in the page
+func (a *ArchiveHelper) ValidResponseWithArchives(count int, archives ...*ArchiveParams) *Response {
+	items := make([]string, 0, len(archives))
+	for _, params := range archives {
+		items = append(items, fmt.Sprintf(archiveResponseBody, params.HasAudio,
+			params.HasVideo, params.ID, params.Name, params.APIKey,
+			params.SessionID, params.Status))
+	}
+	body := fmt.Sprintf(archiveListResponseBody, count, strings.Join(items, ","))
+	return NewResponseWithBody(200, body)
+}
+
 // ValidResponseEmpty generates a 200 empty response
 func (a *ArchiveHelper) ValidResponseEmpty() *Response {
 	return NewResponse(200)
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var callbackHelper *CallbackHelper
+
+func init() {
+	callbackHelper = &CallbackHelper{}
+}
+
+// Callback gives access to a CallbackHelper instance
+func Callback() *CallbackHelper {
+	return callbackHelper
+}
+
+// CallbackHelper is an object helper to generate the signed
+// callbacks sent by the platform
+type CallbackHelper struct {
+}
+
+// Sign generates the JWT that the platform sends with a callback
+// whose body is body
+func (c *CallbackHelper) Sign(secret string, body []byte, issuedAt int64, id string) string {
+	hash := sha256.Sum256(body)
+	return c.SignClaims(secret, map[string]interface{}{
+		"iat":          issuedAt,
+		"jti":          id,
+		"payload_hash": hex.EncodeToString(hash[:]),
+	})
+}
+
+// SignClaims generates an HS256 JWT with the given claims
+func (c *CallbackHelper) SignClaims(secret string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Request generates a signed callback request
+func (c *CallbackHelper) Request(secret, body string, issuedAt int64, id string) *http.Request {
+	req, _ := http.NewRequest("POST", "https://example.com/callback",
+		strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s",
+		c.Sign(secret, []byte(body), issuedAt, id)))
+	return req
+}
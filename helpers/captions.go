@@ -0,0 +1,44 @@
+package helpers
+
+import "fmt"
+
+var captionsResponseBody = "{\"captionsId\" : \"%s\"}"
+
+var captionsHelper *CaptionsHelper
+
+func init() {
+	captionsHelper = &CaptionsHelper{}
+}
+
+// Captions gives access to a CaptionsHelper instance
+func Captions() *CaptionsHelper {
+	return captionsHelper
+}
+
+// CaptionsHelper is an object helper to generate responses
+// for the captions resource
+type CaptionsHelper struct {
+}
+
+// RequestStart generates a request for CaptionsStart
+func (c *CaptionsHelper) RequestStart(apiKey int, body map[string]interface{}) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/captions", baseURL, apiKey)
+	return NewRequestWithBodyJSON("POST", url, body)
+}
+
+// RequestStop generates a request for CaptionsStop
+func (c *CaptionsHelper) RequestStop(apiKey int, captionsID string) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/captions/%s/stop", baseURL, apiKey, captionsID)
+	return NewRequest("POST", url)
+}
+
+// ValidResponse generates a 202 response that will contain
+// the captions id
+func (c *CaptionsHelper) ValidResponse(captionsID string) *Response {
+	return NewResponseWithBody(202, fmt.Sprintf(captionsResponseBody, captionsID))
+}
+
+// ValidResponseEmpty generates a 202 empty response
+func (c *CaptionsHelper) ValidResponseEmpty() *Response {
+	return NewResponse(202)
+}
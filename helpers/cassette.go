@@ -0,0 +1,277 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+)
+
+// redactedHeaders are replaced in the recorded requests so the
+// cassettes can be committed
+var redactedHeaders = []string{"X-TB-PARTNER-AUTH", "Authorization"}
+
+// RecordedRequest is a request saved in a cassette
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response saved in a cassette
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a request and the response it got
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette is a list of interactions that can be saved to a
+// JSON file and replayed by a ReplayClient
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from path
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s could not be parsed: %s", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// RecordingTransport is an http.RoundTripper that performs the
+// requests with another transport and records them in a cassette.
+// The credentials in the request headers are redacted
+type RecordingTransport struct {
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecordingTransport creates a RecordingTransport that performs
+// the requests with transport, or http.DefaultTransport if it is
+// nil. Use it in an http.Client to record real requests
+func NewRecordingTransport(transport http.RoundTripper) *RecordingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &RecordingTransport{transport: transport}
+}
+
+// RoundTrip performs req and records it with its response
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := req.Header.Clone()
+	for _, name := range redactedHeaders {
+		if len(header.Get(name)) > 0 {
+			header.Set(name, "REDACTED")
+		}
+	}
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: header,
+			Body:   string(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     res.Header.Clone(),
+			Body:       string(resBody),
+		},
+	})
+	t.mu.Unlock()
+
+	return res, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far
+func (t *RecordingTransport) Cassette() *Cassette {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := &Cassette{Interactions: make([]Interaction, len(t.cassette.Interactions))}
+	copy(c.Interactions, t.cassette.Interactions)
+	return c
+}
+
+// Save writes the interactions recorded so far to path
+func (t *RecordingTransport) Save(path string) error {
+	return t.Cassette().Save(path)
+}
+
+// readBody reads a body and replaces it so it can be read again
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// Match selects the parts of the requests that a ReplayClient
+// compares
+type Match int
+
+const (
+	// MatchMethod compares the HTTP methods
+	MatchMethod Match = 1 << iota
+
+	// MatchPath compares the URL paths
+	MatchPath
+
+	// MatchQuery compares the query parameters, in any order
+	MatchQuery
+
+	// MatchBody compares the bodies. JSON bodies are compared by
+	// value, so the order of the keys does not matter
+	MatchBody
+
+	// MatchAll compares all the parts of the requests
+	MatchAll = MatchMethod | MatchPath | MatchQuery | MatchBody
+)
+
+// ReplayClient serves the responses of a cassette. Every
+// interaction is served once, in the order of the cassette. It
+// implements the httpClient interface and is safe for concurrent
+// use
+type ReplayClient struct {
+	match Match
+
+	mu   sync.Mutex
+	used []bool
+	c    *Cassette
+}
+
+// NewReplayClient creates a ReplayClient that serves the
+// interactions of c whose requests match on the parts in match
+func NewReplayClient(c *Cassette, match Match) *ReplayClient {
+	return &ReplayClient{
+		match: match,
+		used:  make([]bool, len(c.Interactions)),
+		c:     c,
+	}
+}
+
+// Do returns the response of the first unused interaction that
+// matches req
+func (r *ReplayClient) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.c.Interactions {
+		if r.used[i] || !r.matches(&interaction.Request, req, body) {
+			continue
+		}
+		r.used[i] = true
+		recorded := interaction.Response
+		return &http.Response{
+			StatusCode:    recorded.StatusCode,
+			Header:        recorded.Header.Clone(),
+			Body:          newBody(recorded.Body),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("Could not find request for req: %s %s",
+		req.Method, req.URL.String())
+}
+
+// Remaining returns the number of interactions not served yet
+func (r *ReplayClient) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *ReplayClient) matches(recorded *RecordedRequest, req *http.Request, body []byte) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	if r.match&MatchMethod != 0 && recorded.Method != req.Method {
+		return false
+	}
+	if r.match&MatchPath != 0 && u.Path != req.URL.Path {
+		return false
+	}
+	if r.match&MatchQuery != 0 && !reflect.DeepEqual(u.Query(), req.URL.Query()) {
+		return false
+	}
+	if r.match&MatchBody != 0 && !equalBody([]byte(recorded.Body), body) {
+		return false
+	}
+	return true
+}
+
+// equalBody compares two bodies. JSON bodies are compared by
+// value and other bodies byte by byte
+func equalBody(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
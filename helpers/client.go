@@ -4,8 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
 )
 
 var baseURL = "https://api.opentok.com"
@@ -91,7 +98,8 @@ func NewResponseWithBody(statusCode int, body string) *Response {
 	res.StatusCode = statusCode
 	res.Body = newBody(body)
 	return &Response{
-		res: res,
+		res:  res,
+		body: body,
 	}
 }
 
@@ -118,94 +126,256 @@ func NewResponseWithBodyJSON(statusCode int, body interface{}) *Response {
 // Response is a simple object to encapsulate the
 // *http.Response interface
 type Response struct {
-	res *http.Response
+	res  *http.Response
+	body string
 }
 
-// Client is a simple object to mock up http responses
-type Client struct {
-	reqResMap       map[*Request]*Response
-	defaultResponse *Response
+// AddHeader adds a header to the http.Response
+func (r *Response) AddHeader(key, value string) *Response {
+	if r.res.Header == nil {
+		r.res.Header = make(http.Header)
+	}
+	r.res.Header.Add(key, value)
+	return r
 }
 
-// Add adds a new (request, response) pair that will be used
-// when a request is performed to find the correct response
-func (c *Client) Add(req *Request, res *Response) *Client {
-	c.reqResMap[req] = res
-	return c
+// clone returns a copy of the response with a new body, so the
+// same response can be served several times
+func (r *Response) clone(req *http.Request) *http.Response {
+	res := *r.res
+	res.Header = r.res.Header.Clone()
+	res.Body = newBody(r.body)
+	res.ContentLength = int64(len(r.body))
+	res.Request = req
+	return &res
 }
 
-// Do mocks an http request. It implements the httpClient interface
-func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	res := c.findResponse(req)
-	if res == nil {
-		return nil, fmt.Errorf("Could not find request for req: %s",
-			req.URL.String())
-	}
+// Expectation is a request that the Client expects and the
+// response it serves
+type Expectation struct {
+	method string
+	url    string
+	header http.Header
+	body   []byte
+	res    *Response
+	client *Client
+
+	// times is the number of calls expected, 0 means any number
+	times int
+	calls int
+}
+
+// Times sets the number of times that the request is expected.
+// After that the expectation does not match any more
+func (e *Expectation) Times(n int) *Expectation {
+	e.client.mu.Lock()
+	defer e.client.mu.Unlock()
 
-	return res, nil
+	e.times = n
+	return e
 }
 
-func (c *Client) findResponse(req *http.Request) *http.Response {
-	for cReq, cRes := range c.reqResMap {
-		if equalReq(cReq.req, req) {
-			return cRes.res
-		}
-	}
-	if c.defaultResponse != nil {
-		return c.defaultResponse.res
-	}
-	return nil
+// Calls returns the number of times that the expectation matched
+func (e *Expectation) Calls() int {
+	e.client.mu.Lock()
+	defer e.client.mu.Unlock()
+
+	return e.calls
 }
 
-func equalReq(cReq, req *http.Request) bool {
-	// match urls
-	if cReq.URL.String() != req.URL.String() {
-		return false
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s %s", e.method, e.url)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times > 0 && e.calls >= e.times
+}
+
+// diff returns the differences between the expected request and
+// req. The request matches if there are none
+func (e *Expectation) diff(req *http.Request, body []byte) []string {
+	var diffs []string
+	if e.method != req.Method {
+		diffs = append(diffs, fmt.Sprintf("method: expected %s, got %s",
+			e.method, req.Method))
 	}
-	// match headers
-	for header := range cReq.Header {
-		if req.Header.Get(header) != cReq.Header.Get(header) {
-			return false
+	if !equalURL(e.url, req.URL) {
+		diffs = append(diffs, fmt.Sprintf("url: expected %s, got %s",
+			e.url, req.URL.String()))
+	}
+	names := make([]string, 0, len(e.header))
+	for name := range e.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if expected, got := e.header.Get(name), req.Header.Get(name); expected != got {
+			diffs = append(diffs, fmt.Sprintf("header %s: expected %q, got %q",
+				name, expected, got))
 		}
 	}
+	// JSON bodies are compared by value, so the order of the keys
+	// does not matter
+	if !equalBody(e.body, body) {
+		diffs = append(diffs, fmt.Sprintf("body: expected %q, got %q",
+			e.body, body))
+	}
+	return diffs
+}
 
-	// match body.
-	if cReq.ContentLength != req.ContentLength {
+// equalURL tells whether u is the expected URL. The query
+// parameters can be in any order
+func equalURL(expected string, u *url.URL) bool {
+	if expected == u.String() {
+		return true
+	}
+	e, err := url.Parse(expected)
+	if err != nil {
 		return false
 	}
+	return e.Scheme == u.Scheme && e.Host == u.Host && e.Path == u.Path &&
+		reflect.DeepEqual(e.Query(), u.Query())
+}
 
-	if cReq.ContentLength == 0 {
-		return true
+// Client is a simple object to mock up http responses. The
+// requests are matched against the expectations in the order
+// they were added. It is safe for concurrent use
+type Client struct {
+	mu              sync.Mutex
+	expectations    []*Expectation
+	defaultResponse *Response
+	ordered         bool
+	calls           int
+	unexpected      []string
+}
+
+// Add adds a new (request, response) pair that will be used
+// when a request is performed to find the correct response. The
+// pair can be used any number of times
+func (c *Client) Add(req *Request, res *Response) *Client {
+	c.Expect(req, res)
+	return c
+}
+
+// Expect adds a new (request, response) pair and returns the
+// expectation, which can be limited with Times
+func (c *Client) Expect(req *Request, res *Response) *Expectation {
+	// the body is read once so matching never touches req
+	body, _ := readBody(&req.req.Body)
+	e := &Expectation{
+		method: req.req.Method,
+		url:    req.req.URL.String(),
+		header: req.req.Header.Clone(),
+		body:   body,
+		res:    res,
+		client: c,
 	}
 
-	bodyCReq := make([]byte, cReq.ContentLength)
-	bodyReq := make([]byte, req.ContentLength)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	cReq.Body.Read(bodyCReq)
-	req.Body.Read(bodyReq)
+	c.expectations = append(c.expectations, e)
+	return e
+}
 
-	cReq.Body.Close()
-	req.Body.Close()
+// SetOrdered makes the requests match the expectations only in
+// the order they were added: a request cannot match an expectation
+// until the previous ones have been called
+func (c *Client) SetOrdered(ordered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// We need to restore the body because it will be
-	// used in future comparisons
-	cReq.Body = newBody(string(bodyCReq))
-	if string(bodyCReq) != string(bodyReq) {
-		return false
-	}
-	return true
+	c.ordered = ordered
 }
 
 // SetDefaultResponse sets an optional default response that is
 // used when Client.Do does not find an appropriate response for
 // the provided request
 func (c *Client) SetDefaultResponse(res *Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.defaultResponse = res
 }
 
+// Do mocks an http request. It implements the httpClient interface
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	var closest *Expectation
+	var closestDiffs []string
+	for _, e := range c.expectations {
+		if e.exhausted() {
+			continue
+		}
+		diffs := e.diff(req, body)
+		if len(diffs) == 0 {
+			e.calls++
+			return e.res.clone(req), nil
+		}
+		if closest == nil || len(diffs) < len(closestDiffs) {
+			closest, closestDiffs = e, diffs
+		}
+		if c.ordered && e.calls == 0 {
+			// the next expected request has not been called yet
+			break
+		}
+	}
+
+	if c.defaultResponse != nil {
+		return c.defaultResponse.clone(req), nil
+	}
+
+	message := fmt.Sprintf("Could not find request for req: %s %s",
+		req.Method, req.URL.String())
+	if closest != nil {
+		message = fmt.Sprintf("%s\nclosest expectation %s:\n  %s", message,
+			closest, strings.Join(closestDiffs, "\n  "))
+	}
+	c.unexpected = append(c.unexpected, message)
+	return nil, errors.New(message)
+}
+
+// Calls returns the number of requests performed with the client
+func (c *Client) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls
+}
+
+// AssertExpectationsMet fails the test if an expectation was
+// called a different number of times than expected, if one without
+// a count was never called, or if a request did not match any
+// expectation
+func (c *Client) AssertExpectationsMet(t testing.TB) {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.expectations {
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			t.Errorf("Expected %s to be called %d times, called %d times",
+				e, e.times, e.calls)
+		case e.times == 0 && e.calls == 0:
+			t.Errorf("Expected %s to be called", e)
+		}
+	}
+	for _, message := range c.unexpected {
+		t.Errorf("Unexpected request: %s", message)
+	}
+}
+
 // NewClient creates a new client
 func NewClient() *Client {
-	return &Client{
-		reqResMap: make(map[*Request]*Response),
-	}
+	return &Client{}
 }
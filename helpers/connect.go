@@ -0,0 +1,45 @@
+package helpers
+
+import "fmt"
+
+var connectResponseBody = "{\"id\" : \"%s\",\n \"connectionId\" : \"%s\"}"
+
+var connectHelper *ConnectHelper
+
+func init() {
+	connectHelper = &ConnectHelper{}
+}
+
+// Connect gives access to a ConnectHelper instance
+func Connect() *ConnectHelper {
+	return connectHelper
+}
+
+// ConnectHelper is an object helper to generate responses
+// for the Audio Connector resource
+type ConnectHelper struct {
+}
+
+// RequestConnect generates a request for ConnectAudioToWebSocket
+func (c *ConnectHelper) RequestConnect(apiKey int, body map[string]interface{}) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/connect", baseURL, apiKey)
+	return NewRequestWithBodyJSON("POST", url, body)
+}
+
+// RequestDisconnect generates a request for DisconnectWebSocket
+func (c *ConnectHelper) RequestDisconnect(apiKey int, connectID string) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/connect/%s/stop", baseURL, apiKey, connectID)
+	return NewRequest("POST", url)
+}
+
+// ValidResponse generates a response that will contain the
+// ids of an Audio Connector
+func (c *ConnectHelper) ValidResponse(connectID, connectionID string) *Response {
+	body := fmt.Sprintf(connectResponseBody, connectID, connectionID)
+	return NewResponseWithBody(200, body)
+}
+
+// ValidResponseEmpty generates a 204 empty response
+func (c *ConnectHelper) ValidResponseEmpty() *Response {
+	return NewResponse(204)
+}
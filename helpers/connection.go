@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var connectionResponseBody = "{\"connectionId\" : \"%s\",\n \"connectionState\" : \"%s\",\n \"createdAt\" : 1384221730555,\n \"data\" : %s}"
+
+var connectionListResponseBody = "{ \"count\" : %d, \"projectId\" : %d, \"sessionId\" : \"%s\", \"items\" : [ %s ] }"
+
+// ConnectionParams can be used to set up the desired
+// connection when formatting against connectionResponseBody
+type ConnectionParams struct {
+	ID    string
+	State string
+	Data  string
+}
+
+var connectionHelper *ConnectionHelper
+
+func init() {
+	connectionHelper = &ConnectionHelper{}
+}
+
+// Connection gives access to a ConnectionHelper instance
+func Connection() *ConnectionHelper {
+	return connectionHelper
+}
+
+// ConnectionHelper is an object helper to generate responses
+// for the connection resource
+type ConnectionHelper struct {
+}
+
+// RequestList generates a request for ConnectionList
+func (c *ConnectionHelper) RequestList(apiKey int, sessionID string, count, offset int) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/session/%s/connection?offset=%d&count=%d",
+		baseURL, apiKey, sessionID, offset, count)
+	return NewRequest("GET", url)
+}
+
+// RequestDelete generates a request for ForceDisconnect
+func (c *ConnectionHelper) RequestDelete(apiKey int, sessionID, connectionID string) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/session/%s/connection/%s",
+		baseURL, apiKey, sessionID, connectionID)
+	return NewRequest("DELETE", url)
+}
+
+// ValidResponseWithConnections generates a response that will
+// contain a JSON connection list with the given connections.
+// count is the total number of connections, which can be bigger
+// than the number of connections in the page
+func (c *ConnectionHelper) ValidResponseWithConnections(apiKey int, sessionID string,
+	count int, connections ...*ConnectionParams) *Response {
+
+	items := make([]string, 0, len(connections))
+	for _, params := range connections {
+		// the data is escaped since it can hold any text, e.g. JSON
+		data, _ := json.Marshal(params.Data)
+		items = append(items, fmt.Sprintf(connectionResponseBody,
+			params.ID, params.State, data))
+	}
+	body := fmt.Sprintf(connectionListResponseBody, count, apiKey, sessionID,
+		strings.Join(items, ","))
+	return NewResponseWithBody(200, body)
+}
+
+// ValidResponseEmpty generates a 204 empty response
+func (c *ConnectionHelper) ValidResponseEmpty() *Response {
+	return NewResponse(204)
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+var renderResponseBody = "{\"id\" : \"%s\",\n \"sessionId\" : \"%s\",\n \"projectId\" : %d,\n \"createdAt\" : 1437676551000,\n \"updatedAt\" : 1437676551000,\n \"url\" : \"%s\",\n \"resolution\" : \"1280x720\",\n \"status\" : \"%s\",\n \"streamId\" : \"e32445b743678c98230f238\"}"
+
+var renderListResponseBody = "{ \"count\" : %d, \"items\" : [ %s ] }"
+
+// RenderParams can be used to set up the desired render
+// when formatting against renderResponseBody
+type RenderParams struct {
+	ID        string
+	SessionID string
+	APIKey    int
+	URL       string
+	Status    string
+}
+
+var renderHelper *RenderHelper
+
+func init() {
+	renderHelper = &RenderHelper{}
+}
+
+// Render gives access to a RenderHelper instance
+func Render() *RenderHelper {
+	return renderHelper
+}
+
+// RenderHelper is an object helper to generate responses
+// for the render resource
+type RenderHelper struct {
+}
+
+// DefaultParams returns a default RenderParams struct
+func (r *RenderHelper) DefaultParams() *RenderParams {
+	return &RenderParams{
+		ID:        "renderId",
+		SessionID: "sessionId",
+		APIKey:    123456,
+		URL:       "https://example.com/whiteboard",
+		Status:    "started",
+	}
+}
+
+// RequestStart generates a request for RenderStart
+func (r *RenderHelper) RequestStart(apiKey int, body map[string]interface{}) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/render", baseURL, apiKey)
+	return NewRequestWithBodyJSON("POST", url, body)
+}
+
+// RequestStop generates a request for RenderStop
+func (r *RenderHelper) RequestStop(apiKey int, renderID string) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/render/%s", baseURL, apiKey, renderID)
+	return NewRequest("DELETE", url)
+}
+
+// RequestGet generates a request for RenderGet
+func (r *RenderHelper) RequestGet(apiKey int, renderID string) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/render/%s", baseURL, apiKey, renderID)
+	return NewRequest("GET", url)
+}
+
+// RequestList generates a request for RenderList
+func (r *RenderHelper) RequestList(apiKey, count, offset int) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/render?offset=%d&count=%d",
+		baseURL, apiKey, offset, count)
+	return NewRequest("GET", url)
+}
+
+// ValidResponseWithRender generates a response that will
+// contain a JSON render
+func (r *RenderHelper) ValidResponseWithRender(params *RenderParams) *Response {
+	return NewResponseWithBody(200, r.format(params))
+}
+
+// ValidResponseWithRenders generates a response that will
+// contain a JSON render list with the given renders
+func (r *RenderHelper) ValidResponseWithRenders(count int, renders ...*RenderParams) *Response {
+	items := make([]string, 0, len(renders))
+	for _, params := range renders {
+		items = append(items, r.format(params))
+	}
+	body := fmt.Sprintf(renderListResponseBody, count, strings.Join(items, ","))
+	return NewResponseWithBody(200, body)
+}
+
+// ValidResponseEmpty generates a 204 empty response
+func (r *RenderHelper) ValidResponseEmpty() *Response {
+	return NewResponse(204)
+}
+
+func (r *RenderHelper) format(params *RenderParams) string {
+	return fmt.Sprintf(renderResponseBody, params.ID, params.SessionID,
+		params.APIKey, params.URL, params.Status)
+}
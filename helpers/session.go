@@ -67,6 +67,14 @@ func (s *SessionHelper) ValidResponse(sessionID string, apiKey int) *Response {
 		fmt.Sprintf(sessionResponseBody, sessionID, apiKey))
 }
 
+// ID generates a session id in the format used by the
+// OpenTok platform, with apiKey encoded in it
+func (s *SessionHelper) ID(apiKey int) string {
+	raw := fmt.Sprintf("1~%d~~1435484890874~dXR2ZTdvR2hXV3Nr~", apiKey)
+	encoded := base64.RawStdEncoding.EncodeToString([]byte(raw))
+	return "1_" + strings.NewReplacer("+", "-", "/", "_").Replace(encoded)
+}
+
 // InvalidResponseNoAuth generates a response that is
 // generated when the user tries to authenticate without
 // X-TB-PARTNER-AUTH in the header
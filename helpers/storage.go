@@ -0,0 +1,52 @@
+package helpers
+
+import "fmt"
+
+var storageResponseBodyS3 = "{\"type\" : \"s3\",\n \"config\" : {\"accessKey\" : \"%s\",\n \"bucket\" : \"%s\",\n \"secretKey\" : \"%s\"},\n \"fallback\" : \"%s\"}"
+
+var storageHelper *StorageHelper
+
+func init() {
+	storageHelper = &StorageHelper{}
+}
+
+// Storage gives access to a StorageHelper instance
+func Storage() *StorageHelper {
+	return storageHelper
+}
+
+// StorageHelper is an object helper to generate responses
+// for the archive storage resource
+type StorageHelper struct {
+}
+
+// RequestSet generates a request for SetArchiveStorage
+func (s *StorageHelper) RequestSet(apiKey int, body map[string]interface{}) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/archive/storage", baseURL, apiKey)
+	return NewRequestWithBodyJSON("PUT", url, body)
+}
+
+// RequestGet generates a request for GetArchiveStorage
+func (s *StorageHelper) RequestGet(apiKey int) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/archive/storage", baseURL, apiKey)
+	return NewRequest("GET", url)
+}
+
+// RequestDelete generates a request for DeleteArchiveStorage
+func (s *StorageHelper) RequestDelete(apiKey int) *Request {
+	url := fmt.Sprintf("%s/v2/project/%d/archive/storage", baseURL, apiKey)
+	return NewRequest("DELETE", url)
+}
+
+// ValidResponseWithS3 generates a response that will
+// contain an S3 storage configuration
+func (s *StorageHelper) ValidResponseWithS3(accessKey, bucket, secretKey, fallback string) *Response {
+	body := fmt.Sprintf(storageResponseBodyS3, accessKey, bucket,
+		secretKey, fallback)
+	return NewResponseWithBody(200, body)
+}
+
+// ValidResponseEmpty generates a 200 empty response
+func (s *StorageHelper) ValidResponseEmpty() *Response {
+	return NewResponse(200)
+}
@@ -0,0 +1,259 @@
+package opentok
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Collector records the usage of the OpenTok API. Started and
+// Finished are called around every operation, including token
+// generation, which does not make any request
+type Collector interface {
+	Started(apiKey int, operation string)
+
+	// Finished is called when the operation ends. statusCode
+	// is 0 if no response was received from the platform
+	Finished(apiKey int, operation string, statusCode int,
+		latency time.Duration, err error)
+}
+
+// SetCollector sets the Collector that records the usage of the
+// OpenTok object. It must be called before the object is used
+func (ot *OpenTok) SetCollector(c Collector) {
+	ot.collector = c
+}
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the
+// latency histogram buckets used by NewMetrics
+var DefaultLatencyBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+type metricKey struct {
+	apiKey    int
+	operation string
+}
+
+type countKey struct {
+	metricKey
+	label string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics is a Collector that keeps the usage in memory and
+// serves it in the Prometheus text exposition format. All the
+// metrics are labeled with the project API key and the operation.
+// It is safe for concurrent use and can be shared by several
+// OpenTok objects
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	total     map[countKey]uint64
+	errors    map[countKey]uint64
+	inFlight  map[metricKey]int64
+	latencies map[metricKey]*histogram
+}
+
+// NewMetrics creates a Metrics collector with the
+// DefaultLatencyBuckets
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultLatencyBuckets)
+}
+
+// NewMetricsWithBuckets creates a Metrics collector with the
+// given latency buckets, which must be sorted in ascending order
+func NewMetricsWithBuckets(buckets []float64) *Metrics {
+	return &Metrics{
+		buckets:   buckets,
+		total:     make(map[countKey]uint64),
+		errors:    make(map[countKey]uint64),
+		inFlight:  make(map[metricKey]int64),
+		latencies: make(map[metricKey]*histogram),
+	}
+}
+
+// Started records that an operation is in flight
+func (m *Metrics) Started(apiKey int, operation string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[metricKey{apiKey, operation}]++
+}
+
+// Finished records the result and the latency of an operation
+func (m *Metrics) Finished(apiKey int, operation string, statusCode int,
+	latency time.Duration, err error) {
+
+	key := metricKey{apiKey, operation}
+	code := "ok"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	} else if err != nil {
+		code = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[key]--
+	m.total[countKey{key, code}]++
+	if err != nil {
+		m.errors[countKey{key, errorClass(statusCode)}]++
+	}
+
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[key] = h
+	}
+	seconds := latency.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Count returns the number of operations of a project that have
+// finished, regardless of their result
+func (m *Metrics) Count(apiKey int, operation string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.latencies[metricKey{apiKey, operation}]
+	if !ok {
+		return 0
+	}
+	return h.count
+}
+
+// errorClass groups the errors by status code class, e.g. 4xx.
+// Errors without a response are transport errors
+func errorClass(statusCode int) string {
+	if statusCode == 0 {
+		return "transport"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text
+// exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text
+// exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ew := &errWriter{w: w}
+
+	ew.printf("# HELP opentok_operations_total Operations finished by result code.\n")
+	ew.printf("# TYPE opentok_operations_total counter\n")
+	for _, k := range sortedCountKeys(m.total) {
+		ew.printf("opentok_operations_total{%s,code=%q} %d\n",
+			k.labels(), k.label, m.total[k])
+	}
+
+	ew.printf("# HELP opentok_operation_errors_total Operations failed by error class.\n")
+	ew.printf("# TYPE opentok_operation_errors_total counter\n")
+	for _, k := range sortedCountKeys(m.errors) {
+		ew.printf("opentok_operation_errors_total{%s,class=%q} %d\n",
+			k.labels(), k.label, m.errors[k])
+	}
+
+	ew.printf("# HELP opentok_operations_in_flight Operations currently running.\n")
+	ew.printf("# TYPE opentok_operations_in_flight gauge\n")
+	inFlight := make([]metricKey, 0, len(m.inFlight))
+	for k := range m.inFlight {
+		inFlight = append(inFlight, k)
+	}
+	sortMetricKeys(inFlight)
+	for _, k := range inFlight {
+		ew.printf("opentok_operations_in_flight{%s} %d\n", k.labels(), m.inFlight[k])
+	}
+
+	ew.printf("# HELP opentok_operation_duration_seconds Latency of the operations.\n")
+	ew.printf("# TYPE opentok_operation_duration_seconds histogram\n")
+	latencies := make([]metricKey, 0, len(m.latencies))
+	for k := range m.latencies {
+		latencies = append(latencies, k)
+	}
+	sortMetricKeys(latencies)
+	for _, k := range latencies {
+		h := m.latencies[k]
+		for i, bound := range m.buckets {
+			ew.printf("opentok_operation_duration_seconds_bucket{%s,le=%q} %d\n",
+				k.labels(), strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		ew.printf("opentok_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n",
+			k.labels(), h.count)
+		ew.printf("opentok_operation_duration_seconds_sum{%s} %g\n", k.labels(), h.sum)
+		ew.printf("opentok_operation_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	return ew.n, ew.err
+}
+
+func (k metricKey) labels() string {
+	return fmt.Sprintf("api_key=\"%d\",operation=%q", k.apiKey, k.operation)
+}
+
+func (k metricKey) less(o metricKey) bool {
+	if k.apiKey != o.apiKey {
+		return k.apiKey < o.apiKey
+	}
+	return k.operation < o.operation
+}
+
+func sortMetricKeys(keys []metricKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+}
+
+func sortedCountKeys(m map[countKey]uint64) []countKey {
+	keys := make([]countKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].metricKey != keys[j].metricKey {
+			return keys[i].metricKey.less(keys[j].metricKey)
+		}
+		return keys[i].label < keys[j].label
+	})
+	return keys
+}
+
+// errWriter keeps the first error and the number of bytes written
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(ew.w, format, args...)
+	ew.n += int64(n)
+	ew.err = err
+}
@@ -0,0 +1,76 @@
+package opentok
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestMetrics(t *testing.T) {
+	req := helpers.Archive().RequestStop(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Archive().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	client.SetDefaultResponse(helpers.Archive().InvalidResponseAuth())
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	metrics := NewMetrics()
+	ot.SetCollector(metrics)
+
+	ot.ArchiveStop(archiveID)
+	ot.ArchiveStop("unknown")
+	ot.Token(sessionID, nil)
+
+	if count := metrics.Count(apiKey, "ArchiveStop"); count != 2 {
+		t.Fatalf("Unexpected ArchiveStop count: %d", count)
+	}
+	if count := metrics.Count(apiKey, "Token"); count != 1 {
+		t.Fatalf("Unexpected Token count: %d", count)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	for _, line := range []string{
+		`opentok_operations_total{api_key="123456",operation="ArchiveStop",code="200"} 1`,
+		`opentok_operations_total{api_key="123456",operation="ArchiveStop",code="403"} 1`,
+		`opentok_operations_total{api_key="123456",operation="Token",code="ok"} 1`,
+		`opentok_operation_errors_total{api_key="123456",operation="ArchiveStop",class="4xx"} 1`,
+		`opentok_operations_in_flight{api_key="123456",operation="ArchiveStop"} 0`,
+		`opentok_operation_duration_seconds_count{api_key="123456",operation="ArchiveStop"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	metrics := NewMetricsWithBuckets([]float64{0.1, 1})
+
+	metrics.Started(apiKey, "Session")
+	metrics.Finished(apiKey, "Session", 200, 50*time.Millisecond, nil)
+	metrics.Started(apiKey, "Session")
+	metrics.Finished(apiKey, "Session", 0, 2*time.Second, fmt.Errorf("connection refused"))
+
+	var buf strings.Builder
+	metrics.WriteTo(&buf)
+	body := buf.String()
+
+	for _, line := range []string{
+		`opentok_operation_duration_seconds_bucket{api_key="123456",operation="Session",le="0.1"} 1`,
+		`opentok_operation_duration_seconds_bucket{api_key="123456",operation="Session",le="1"} 1`,
+		`opentok_operation_duration_seconds_bucket{api_key="123456",operation="Session",le="+Inf"} 2`,
+		`opentok_operations_total{api_key="123456",operation="Session",code="error"} 1`,
+		`opentok_operation_errors_total{api_key="123456",operation="Session",class="transport"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Expected line %q in:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package opentok
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session monitoring events sent by the platform
+const (
+	ConnectionCreated   = "connectionCreated"
+	ConnectionDestroyed = "connectionDestroyed"
+	StreamCreated       = "streamCreated"
+	StreamDestroyed     = "streamDestroyed"
+)
+
+// MonitorEvent is a session monitoring callback
+type MonitorEvent struct {
+	SessionID string `json:"sessionId"`
+	ProjectID string `json:"projectId"`
+	Event     string `json:"event"`
+
+	// Timestamp is the Unix time in milliseconds of the event.
+	// It is used to put the events in order
+	Timestamp int64 `json:"timestamp"`
+
+	// Reason explains why a connection or a stream was destroyed
+	Reason string `json:"reason,omitempty"`
+
+	Connection *EventConnection `json:"connection,omitempty"`
+	Stream     *EventStream     `json:"stream,omitempty"`
+}
+
+// EventConnection is the connection of a MonitorEvent
+type EventConnection struct {
+	ID        string `json:"id"`
+	CreatedAt int64  `json:"createdAt"`
+	Data      string `json:"data"`
+}
+
+// EventStream is the stream of a MonitorEvent
+type EventStream struct {
+	ID         string          `json:"id"`
+	Connection EventConnection `json:"connection"`
+	CreatedAt  int64           `json:"createdAt"`
+	Name       string          `json:"name"`
+	VideoType  string          `json:"videoType"`
+}
+
+// RosterEntry is a connection or a stream of a session
+type RosterEntry struct {
+	ID string `json:"id"`
+
+	// ConnectionID is the connection that publishes a stream
+	ConnectionID string `json:"connectionId,omitempty"`
+
+	// Data is the connection data of a connection
+	Data string `json:"data,omitempty"`
+
+	// Name and VideoType describe a stream
+	Name      string `json:"name,omitempty"`
+	VideoType string `json:"videoType,omitempty"`
+
+	CreatedAt int64 `json:"createdAt"`
+
+	// Active is false once the connection or the stream has been
+	// destroyed. Destroyed entries are kept for a while to ignore
+	// the events that arrive late
+	Active bool `json:"active"`
+
+	// Updated is the timestamp of the last event applied
+	Updated int64 `json:"updated"`
+}
+
+// SessionRoster is the state of a session kept in a RosterStore
+type SessionRoster struct {
+	SessionID   string                  `json:"sessionId"`
+	Connections map[string]*RosterEntry `json:"connections"`
+	Streams     map[string]*RosterEntry `json:"streams"`
+}
+
+func newSessionRoster(sessionID string) *SessionRoster {
+	return &SessionRoster{
+		SessionID:   sessionID,
+		Connections: make(map[string]*RosterEntry),
+		Streams:     make(map[string]*RosterEntry),
+	}
+}
+
+func (r *SessionRoster) copy() *SessionRoster {
+	c := newSessionRoster(r.SessionID)
+	for id, entry := range r.Connections {
+		e := *entry
+		c.Connections[id] = &e
+	}
+	for id, entry := range r.Streams {
+		e := *entry
+		c.Streams[id] = &e
+	}
+	return c
+}
+
+// RosterStore keeps the roster of every session. Implementations
+// must be safe for concurrent use
+type RosterStore interface {
+	// Get returns the roster of the session, or nil if the
+	// session is unknown
+	Get(sessionID string) (*SessionRoster, error)
+	Put(r *SessionRoster) error
+	Delete(sessionID string) error
+	List() ([]*SessionRoster, error)
+}
+
+// MemoryRosterStore is a RosterStore that keeps the rosters in
+// memory
+type MemoryRosterStore struct {
+	mu      sync.RWMutex
+	rosters map[string]*SessionRoster
+}
+
+// NewMemoryRosterStore creates an empty MemoryRosterStore
+func NewMemoryRosterStore() *MemoryRosterStore {
+	return &MemoryRosterStore{rosters: make(map[string]*SessionRoster)}
+}
+
+// Get returns a copy of the roster of the session
+func (s *MemoryRosterStore) Get(sessionID string) (*SessionRoster, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if r, ok := s.rosters[sessionID]; ok {
+		return r.copy(), nil
+	}
+	return nil, nil
+}
+
+// Put adds or replaces the roster of a session
+func (s *MemoryRosterStore) Put(r *SessionRoster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rosters[r.SessionID] = r.copy()
+	return nil
+}
+
+// Delete removes the roster of the session
+func (s *MemoryRosterStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.rosters, sessionID)
+	return nil
+}
+
+// List returns a copy of all the rosters
+func (s *MemoryRosterStore) List() ([]*SessionRoster, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*SessionRoster, 0, len(s.rosters))
+	for _, r := range s.rosters {
+		list = append(list, r.copy())
+	}
+	return list, nil
+}
+
+// Roster is the list of active connections and streams of a
+// session, sorted by creation time
+type Roster struct {
+	SessionID   string
+	Connections []RosterEntry
+	Streams     []RosterEntry
+}
+
+// SessionOccupancy is the number of active connections and
+// streams of a session
+type SessionOccupancy struct {
+	SessionID   string
+	Connections int
+	Streams     int
+}
+
+// RosterChange is a change of a session roster. Connections and
+// Streams are the number of active ones after the change
+type RosterChange struct {
+	SessionID   string
+	Event       string
+	Entry       RosterEntry
+	Connections int
+	Streams     int
+}
+
+// rosterTombstoneTTL is how long destroyed entries are kept to
+// ignore the events that arrive late
+const rosterTombstoneTTL = time.Hour
+
+// SessionMonitor keeps a live roster of the sessions from the
+// session monitoring callbacks. Events may arrive out of order or
+// more than once: they are put in order by their timestamp and a
+// connection or stream that has been destroyed is never brought
+// back by an older event
+type SessionMonitor struct {
+	store    RosterStore
+	verifier *CallbackVerifier
+
+	// mu serializes the updates of the rosters and notifyMu keeps
+	// the notifications in the same order. subMu only guards the
+	// subscribers, so they can be changed from a notification
+	mu          sync.Mutex
+	notifyMu    sync.Mutex
+	subMu       sync.Mutex
+	subscribers map[int]func(RosterChange)
+	nextID      int
+}
+
+// NewSessionMonitor creates a SessionMonitor that keeps the
+// rosters in store. If store is nil, the rosters are kept in a
+// MemoryRosterStore
+func NewSessionMonitor(store RosterStore) *SessionMonitor {
+	if store == nil {
+		store = NewMemoryRosterStore()
+	}
+	return &SessionMonitor{
+		store:       store,
+		subscribers: make(map[int]func(RosterChange)),
+	}
+}
+
+// SetVerifier makes ServeHTTP reject the callbacks that do not
+// pass the verification of v. It must be called before the
+// monitor handles any request
+func (m *SessionMonitor) SetVerifier(v *CallbackVerifier) {
+	m.verifier = v
+}
+
+// Subscribe calls fn with every change of the rosters, in the
+// order they are applied. fn may query the monitor, subscribe
+// and cancel subscriptions, but must not handle events. The
+// returned function cancels the subscription
+func (m *SessionMonitor) Subscribe(fn func(RosterChange)) (cancel func()) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = fn
+	return func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// subscriber returns the function of subscription id, or nil if
+// it has been cancelled
+func (m *SessionMonitor) subscriber(id int) func(RosterChange) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	return m.subscribers[id]
+}
+
+// ServeHTTP handles a session monitoring callback. Events other
+// than connection and stream events are ignored
+func (m *SessionMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if m.verifier != nil {
+		if _, err := m.verifier.Verify(r); err != nil {
+			writeJSONError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+	}
+
+	var event MonitorEvent
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxCallbackSize)).Decode(&event); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid event")
+		return
+	}
+	if err := m.HandleEvent(&event); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleEvent applies an event to the roster of its session
+func (m *SessionMonitor) HandleEvent(e *MonitorEvent) error {
+	switch e.Event {
+	case ConnectionCreated, ConnectionDestroyed:
+		if e.Connection == nil || len(e.Connection.ID) == 0 {
+			return fmt.Errorf("%s event without connection", e.Event)
+		}
+	case StreamCreated, StreamDestroyed:
+		if e.Stream == nil || len(e.Stream.ID) == 0 {
+			return fmt.Errorf("%s event without stream", e.Event)
+		}
+	default:
+		return nil
+	}
+	if len(e.SessionID) == 0 {
+		return fmt.Errorf("Session has empty id")
+	}
+
+	m.mu.Lock()
+	r, err := m.store.Get(e.SessionID)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	if r == nil {
+		r = newSessionRoster(e.SessionID)
+	}
+
+	changes := applyEvent(r, e)
+	pruneRoster(r, e.Timestamp-int64(rosterTombstoneTTL/time.Millisecond))
+	if len(r.Connections) == 0 && len(r.Streams) == 0 {
+		err = m.store.Delete(r.SessionID)
+	} else {
+		err = m.store.Put(r)
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+
+	connections, streams := countActive(r)
+	for i := range changes {
+		changes[i].Connections = connections
+		changes[i].Streams = streams
+	}
+
+	// hand over to the notification lock so the subscribers get
+	// the changes in order without blocking the queries
+	m.notifyMu.Lock()
+	m.mu.Unlock()
+	defer m.notifyMu.Unlock()
+
+	m.subMu.Lock()
+	ids := make([]int, 0, len(m.subscribers))
+	for id := range m.subscribers {
+		ids = append(ids, id)
+	}
+	m.subMu.Unlock()
+	sort.Ints(ids)
+
+	// subMu is not held while fn runs, and a subscription that is
+	// cancelled does not get the rest of the changes
+	for _, change := range changes {
+		for _, id := range ids {
+			if fn := m.subscriber(id); fn != nil {
+				fn(change)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEvent updates r with e and returns the changes
+func applyEvent(r *SessionRoster, e *MonitorEvent) []RosterChange {
+	var changes []RosterChange
+	record := func(event string, entry *RosterEntry, changed bool) {
+		if changed {
+			changes = append(changes, RosterChange{
+				SessionID: r.SessionID,
+				Event:     event,
+				Entry:     *entry,
+			})
+		}
+	}
+
+	switch e.Event {
+	case ConnectionCreated, ConnectionDestroyed:
+		entry := connectionEntry(e.Connection)
+		active := e.Event == ConnectionCreated
+		record(e.Event, entry, updateEntry(r.Connections, entry, active, e.Timestamp))
+
+		if !active {
+			// the streams of the connection go away with it, even
+			// if their own events have not arrived yet
+			for _, stream := range r.Streams {
+				if stream.ConnectionID == entry.ID && stream.Active &&
+					stream.Updated <= e.Timestamp {
+					stream.Active = false
+					stream.Updated = e.Timestamp
+					record(StreamDestroyed, stream, true)
+				}
+			}
+		}
+
+	case StreamCreated:
+		entry := streamEntry(e.Stream)
+		connection, ok := r.Connections[entry.ConnectionID]
+		if ok && !connection.Active && connection.Updated >= e.Timestamp {
+			// the connection was destroyed after the stream was
+			// created, so the stream is already gone
+			updateEntry(r.Streams, entry, false, connection.Updated)
+			return changes
+		}
+		if !ok && len(entry.ConnectionID) > 0 {
+			// the connectionCreated event has not arrived yet
+			c := connectionEntry(&e.Stream.Connection)
+			record(ConnectionCreated, c, updateEntry(r.Connections, c, true, e.Timestamp))
+		}
+		record(e.Event, entry, updateEntry(r.Streams, entry, true, e.Timestamp))
+
+	case StreamDestroyed:
+		entry := streamEntry(e.Stream)
+		record(e.Event, entry, updateEntry(r.Streams, entry, false, e.Timestamp))
+	}
+	return changes
+}
+
+// updateEntry applies an event at timestamp to the entry with the
+// id of entry and tells whether the entry became active or
+// inactive. Older events only fill in missing details, and for
+// events at the same time destroying wins
+func updateEntry(entries map[string]*RosterEntry, entry *RosterEntry,
+	active bool, timestamp int64) bool {
+
+	existing, ok := entries[entry.ID]
+	if ok {
+		// late events and duplicates may have more details
+		mergeEntry(existing, entry)
+		if timestamp < existing.Updated ||
+			(timestamp == existing.Updated && !existing.Active) {
+			return false
+		}
+		if existing.Active == active {
+			existing.Updated = timestamp
+			return false
+		}
+	}
+
+	entry.Active = active
+	entry.Updated = timestamp
+	if ok {
+		mergeEntry(entry, existing)
+	}
+	entries[entry.ID] = entry
+	return active || ok
+}
+
+// mergeEntry fills the empty fields of dst with the ones of src
+func mergeEntry(dst, src *RosterEntry) {
+	if len(dst.ConnectionID) == 0 {
+		dst.ConnectionID = src.ConnectionID
+	}
+	if len(dst.Data) == 0 {
+		dst.Data = src.Data
+	}
+	if len(dst.Name) == 0 {
+		dst.Name = src.Name
+	}
+	if len(dst.VideoType) == 0 {
+		dst.VideoType = src.VideoType
+	}
+	if dst.CreatedAt == 0 {
+		dst.CreatedAt = src.CreatedAt
+	}
+}
+
+func connectionEntry(c *EventConnection) *RosterEntry {
+	return &RosterEntry{
+		ID:        c.ID,
+		Data:      c.Data,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func streamEntry(s *EventStream) *RosterEntry {
+	return &RosterEntry{
+		ID:           s.ID,
+		ConnectionID: s.Connection.ID,
+		Name:         s.Name,
+		VideoType:    s.VideoType,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+// pruneRoster removes the destroyed entries older than before
+func pruneRoster(r *SessionRoster, before int64) {
+	for _, entries := range []map[string]*RosterEntry{r.Connections, r.Streams} {
+		for id, entry := range entries {
+			if !entry.Active && entry.Updated < before {
+				delete(entries, id)
+			}
+		}
+	}
+}
+
+func countActive(r *SessionRoster) (connections, streams int) {
+	for _, entry := range r.Connections {
+		if entry.Active {
+			connections++
+		}
+	}
+	for _, entry := range r.Streams {
+		if entry.Active {
+			streams++
+		}
+	}
+	return connections, streams
+}
+
+// Roster returns the active connections and streams of the
+// session. It is empty if the session is unknown
+func (m *SessionMonitor) Roster(sessionID string) (*Roster, error) {
+	r, err := m.store.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	roster := &Roster{SessionID: sessionID}
+	if r == nil {
+		return roster, nil
+	}
+	roster.Connections = activeEntries(r.Connections)
+	roster.Streams = activeEntries(r.Streams)
+	return roster, nil
+}
+
+func activeEntries(entries map[string]*RosterEntry) []RosterEntry {
+	list := make([]RosterEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Active {
+			list = append(list, *entry)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt < list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// ActiveSessions returns the occupancy of the sessions that have
+// at least one active connection, sorted by session id
+func (m *SessionMonitor) ActiveSessions() ([]SessionOccupancy, error) {
+	rosters, err := m.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionOccupancy
+	for _, r := range rosters {
+		connections, streams := countActive(r)
+		if connections > 0 {
+			sessions = append(sessions, SessionOccupancy{
+				SessionID:   r.SessionID,
+				Connections: connections,
+				Streams:     streams,
+			})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions, nil
+}
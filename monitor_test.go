@@ -0,0 +1,219 @@
+package opentok
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func connectionEvent(event, connectionID string, timestamp int64) *MonitorEvent {
+	return &MonitorEvent{
+		SessionID: "session",
+		Event:     event,
+		Timestamp: timestamp,
+		Connection: &EventConnection{
+			ID:        connectionID,
+			CreatedAt: timestamp,
+			Data:      "name=" + connectionID,
+		},
+	}
+}
+
+func streamEvent(event, streamID, connectionID string, timestamp int64) *MonitorEvent {
+	return &MonitorEvent{
+		SessionID: "session",
+		Event:     event,
+		Timestamp: timestamp,
+		Stream: &EventStream{
+			ID:         streamID,
+			Connection: EventConnection{ID: connectionID},
+			CreatedAt:  timestamp,
+			VideoType:  "camera",
+		},
+	}
+}
+
+func handleEvents(t *testing.T, m *SessionMonitor, events ...*MonitorEvent) {
+	for _, e := range events {
+		if err := m.HandleEvent(e); err != nil {
+			t.Fatalf("Expected err to be nil: %s", err)
+		}
+	}
+}
+
+func checkRoster(t *testing.T, m *SessionMonitor, connections, streams int) *Roster {
+	roster, err := m.Roster("session")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if len(roster.Connections) != connections || len(roster.Streams) != streams {
+		t.Fatalf("Unexpected roster: %+v, expected %d connections and %d streams",
+			roster, connections, streams)
+	}
+	return roster
+}
+
+func TestSessionMonitorRoster(t *testing.T) {
+	m := NewSessionMonitor(nil)
+	handleEvents(t, m,
+		connectionEvent(ConnectionCreated, "c1", 10),
+		connectionEvent(ConnectionCreated, "c2", 20),
+		streamEvent(StreamCreated, "s1", "c1", 30),
+	)
+
+	roster := checkRoster(t, m, 2, 1)
+	if roster.Connections[0].ID != "c1" || roster.Connections[0].Data != "name=c1" {
+		t.Fatalf("Expected the connections sorted by creation: %+v", roster.Connections)
+	}
+	if roster.Streams[0].ConnectionID != "c1" {
+		t.Fatalf("Unexpected stream: %+v", roster.Streams[0])
+	}
+
+	sessions, _ := m.ActiveSessions()
+	if len(sessions) != 1 || sessions[0].Connections != 2 || sessions[0].Streams != 1 {
+		t.Fatalf("Unexpected active sessions: %+v", sessions)
+	}
+
+	// the streams of a connection go away with it
+	handleEvents(t, m, connectionEvent(ConnectionDestroyed, "c1", 40))
+	checkRoster(t, m, 1, 0)
+
+	handleEvents(t, m, connectionEvent(ConnectionDestroyed, "c2", 50))
+	checkRoster(t, m, 0, 0)
+	if sessions, _ = m.ActiveSessions(); len(sessions) != 0 {
+		t.Fatalf("Expected no active sessions: %+v", sessions)
+	}
+}
+
+func TestSessionMonitorOutOfOrder(t *testing.T) {
+	m := NewSessionMonitor(nil)
+
+	// destroyed events that arrive before the created ones
+	handleEvents(t, m,
+		connectionEvent(ConnectionDestroyed, "c1", 20),
+		connectionEvent(ConnectionCreated, "c1", 10),
+		streamEvent(StreamDestroyed, "s2", "c2", 40),
+		streamEvent(StreamCreated, "s2", "c2", 30),
+	)
+	checkRoster(t, m, 1, 0)
+
+	// a stream created before its connection implies it
+	handleEvents(t, m, streamEvent(StreamCreated, "s3", "c3", 50))
+	checkRoster(t, m, 2, 1)
+	handleEvents(t, m, connectionEvent(ConnectionCreated, "c3", 45))
+	roster := checkRoster(t, m, 2, 1)
+	for _, c := range roster.Connections {
+		if c.ID == "c3" && c.Data != "name=c3" {
+			t.Fatalf("Expected the late event to fill the details: %+v", c)
+		}
+	}
+
+	// a stream of a connection destroyed later is already gone
+	handleEvents(t, m,
+		connectionEvent(ConnectionDestroyed, "c3", 70),
+		streamEvent(StreamCreated, "s4", "c3", 60),
+	)
+	checkRoster(t, m, 1, 0)
+}
+
+func TestSessionMonitorSubscribe(t *testing.T) {
+	m := NewSessionMonitor(nil)
+	var changes []RosterChange
+	cancel := m.Subscribe(func(c RosterChange) {
+		// subscribers can query the monitor
+		m.Roster(c.SessionID)
+		changes = append(changes, c)
+	})
+
+	handleEvents(t, m,
+		connectionEvent(ConnectionCreated, "c1", 10),
+		connectionEvent(ConnectionCreated, "c1", 10),
+		streamEvent(StreamCreated, "s1", "c1", 20),
+		connectionEvent(ConnectionDestroyed, "c1", 30),
+		connectionEvent(ConnectionDestroyed, "c1", 30),
+	)
+
+	expected := []string{ConnectionCreated, StreamCreated, ConnectionDestroyed,
+		StreamDestroyed}
+	if len(changes) != len(expected) {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+	for i, event := range expected {
+		if changes[i].Event != event {
+			t.Fatalf("Unexpected change %d: %s, expected %s", i,
+				changes[i].Event, event)
+		}
+	}
+	if changes[1].Connections != 1 || changes[1].Streams != 1 {
+		t.Fatalf("Unexpected occupancy: %+v", changes[1])
+	}
+
+	cancel()
+	handleEvents(t, m, connectionEvent(ConnectionCreated, "c2", 40))
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected no changes after cancel: %+v", changes)
+	}
+}
+
+func TestSessionMonitorCancelInCallback(t *testing.T) {
+	m := NewSessionMonitor(nil)
+	var (
+		cancel func()
+		calls  int
+	)
+	cancel = m.Subscribe(func(c RosterChange) {
+		calls++
+		cancel()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// the destroyed connection also destroys its stream, so
+		// the event has two changes
+		for _, e := range []*MonitorEvent{
+			connectionEvent(ConnectionCreated, "c1", 10),
+			streamEvent(StreamCreated, "s1", "c1", 20),
+			connectionEvent(ConnectionDestroyed, "c1", 30),
+		} {
+			if err := m.HandleEvent(e); err != nil {
+				t.Errorf("Expected err to be nil: %s", err)
+			}
+		}
+		m.Subscribe(func(RosterChange) {})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("The monitor is blocked")
+	}
+	if calls != 1 {
+		t.Fatalf("Unexpected number of calls: %d", calls)
+	}
+}
+
+func TestSessionMonitorServeHTTP(t *testing.T) {
+	m := NewSessionMonitor(NewMemoryRosterStore())
+	m.SetVerifier(NewCallbackVerifier(apiSecret, nil))
+
+	body, _ := json.Marshal(connectionEvent(ConnectionCreated, "c1", 10))
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, helpers.Callback().Request(apiSecret, string(body),
+		time.Now().Unix(), "nonce"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected status code: %d", w.Code)
+	}
+	checkRoster(t, m, 1, 0)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("POST", "/monitor", strings.NewReader(string(body))))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected unsigned events to be rejected: %d", w.Code)
+	}
+}
@@ -0,0 +1,240 @@
+package opentok
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestEvent describes a request made to the OpenTok platform.
+// The X-TB-PARTNER-AUTH header is redacted from Header
+type RequestEvent struct {
+	// ID is unique for each request and is shared by the events
+	// of the same request
+	ID uint64
+
+	// Operation is the name of the OpenTok method that made the
+	// request, e.g. ArchiveStart
+	Operation string
+
+	Method string
+
+	// Endpoint is the path template of the request, e.g.
+	// /v2/partner/{apiKey}/archive/{archiveId}. Unlike URL, it
+	// can be used to group requests
+	Endpoint string
+
+	URL    string
+	Header http.Header
+}
+
+// ResponseEvent describes a successful response from the
+// OpenTok platform
+type ResponseEvent struct {
+	RequestEvent
+	StatusCode int
+	Latency    time.Duration
+}
+
+// ErrorEvent describes a request that failed, either because
+// the OpenTok platform could not be reached or because it
+// returned an error status code
+type ErrorEvent struct {
+	RequestEvent
+
+	// StatusCode is 0 if no response was received
+	StatusCode int
+	Latency    time.Duration
+
+	// Err is the error returned to the caller. Use Message to
+	// log it, since the error may contain tokens
+	Err error
+}
+
+// Message returns the error message with any token redacted
+func (e *ErrorEvent) Message() string {
+	return redactTokens(e.Err.Error())
+}
+
+// Observer is notified around every request that an OpenTok
+// object makes. The methods are called synchronously from the
+// goroutine that makes the request, so they should not block
+type Observer interface {
+	OnRequest(e *RequestEvent)
+	OnResponse(e *ResponseEvent)
+	OnError(e *ErrorEvent)
+}
+
+// AddObserver registers o to be notified of every request.
+// It must be called before the OpenTok object is used
+func (ot *OpenTok) AddObserver(o Observer) {
+	ot.observers = append(ot.observers, o)
+}
+
+var requestID uint64
+
+// do performs req within the rate limit and notifies the observers
+// and the collector. Responses with an error status code are
+// turned into errors and their body is closed. Otherwise the
+// caller must close the body, see execute
+func (ot *OpenTok) do(req *http.Request, operation, endpoint string) (*http.Response, error) {
+	if ot.limiter != nil {
+		if err := ot.limiter.acquire(operation); err != nil {
+			return nil, err
+		}
+	}
+
+	var event *RequestEvent
+	if len(ot.observers) > 0 {
+		event = &RequestEvent{
+			ID:        atomic.AddUint64(&requestID, 1),
+			Operation: operation,
+			Method:    req.Method,
+			Endpoint:  endpoint,
+			URL:       req.URL.String(),
+			Header:    redactHeader(req.Header),
+		}
+		for _, o := range ot.observers {
+			o.OnRequest(event)
+		}
+	}
+
+	if ot.collector != nil {
+		ot.collector.Started(ot.APIKey, operation)
+	}
+
+	start := time.Now()
+	res, err := ot.client.Do(req)
+	statusCode := 0
+	if err == nil {
+		statusCode = res.StatusCode
+		if res.StatusCode == http.StatusTooManyRequests && ot.limiter != nil {
+			ot.limiter.throttle(operation, res)
+		}
+		// check that request status code is not an error
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			err = errFromStatusCode(res, operation)
+			closeBody(res)
+		}
+	}
+
+	if ot.collector != nil {
+		ot.collector.Finished(ot.APIKey, operation, statusCode,
+			time.Since(start), err)
+	}
+
+	if event != nil {
+		latency := time.Since(start)
+		for _, o := range ot.observers {
+			if err != nil {
+				o.OnError(&ErrorEvent{
+					RequestEvent: *event,
+					StatusCode:   statusCode,
+					Latency:      latency,
+					Err:          err,
+				})
+			} else {
+				o.OnResponse(&ResponseEvent{
+					RequestEvent: *event,
+					StatusCode:   statusCode,
+					Latency:      latency,
+				})
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+var tokenPattern = regexp.MustCompile(`T1==[A-Za-z0-9+/=]+`)
+
+func redactTokens(s string) string {
+	return tokenPattern.ReplaceAllString(s, "T1==REDACTED")
+}
+
+func redactHeader(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for key, values := range h {
+		redacted[key] = append([]string(nil), values...)
+	}
+	if len(redacted.Get("X-TB-PARTNER-AUTH")) > 0 {
+		redacted.Set("X-TB-PARTNER-AUTH", "REDACTED")
+	}
+	return redacted
+}
+
+// Span is the subset of a tracing span used by SpanObserver.
+// It is small enough to wrap an OpenTelemetry span
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(message string)
+	End()
+}
+
+// Tracer starts the spans for SpanObserver
+type Tracer interface {
+	Start(name string) Span
+}
+
+// SpanObserver is an Observer that creates a span for every
+// request, named after the operation
+type SpanObserver struct {
+	tracer Tracer
+
+	mu    sync.Mutex
+	spans map[uint64]Span
+}
+
+// NewSpanObserver creates a SpanObserver that starts the spans
+// with tracer
+func NewSpanObserver(tracer Tracer) *SpanObserver {
+	return &SpanObserver{
+		tracer: tracer,
+		spans:  make(map[uint64]Span),
+	}
+}
+
+// OnRequest starts the span of the request
+func (s *SpanObserver) OnRequest(e *RequestEvent) {
+	span := s.tracer.Start("opentok." + e.Operation)
+	span.SetAttribute("http.method", e.Method)
+	span.SetAttribute("http.route", e.Endpoint)
+	span.SetAttribute("http.url", e.URL)
+
+	s.mu.Lock()
+	s.spans[e.ID] = span
+	s.mu.Unlock()
+}
+
+// OnResponse ends the span of the request
+func (s *SpanObserver) OnResponse(e *ResponseEvent) {
+	if span := s.take(e.ID); span != nil {
+		span.SetAttribute("http.status_code", e.StatusCode)
+		span.End()
+	}
+}
+
+// OnError records the error and ends the span of the request
+func (s *SpanObserver) OnError(e *ErrorEvent) {
+	if span := s.take(e.ID); span != nil {
+		if e.StatusCode > 0 {
+			span.SetAttribute("http.status_code", e.StatusCode)
+		}
+		span.RecordError(e.Message())
+		span.End()
+	}
+}
+
+func (s *SpanObserver) take(id uint64) Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := s.spans[id]
+	delete(s.spans, id)
+	return span
+}
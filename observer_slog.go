@@ -0,0 +1,55 @@
+//go:build go1.21
+
+package opentok
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogObserver is an Observer that logs every request with a
+// log/slog Logger. Requests and successful responses are logged
+// at debug level and errors at error level
+type SlogObserver struct {
+	logger *slog.Logger
+}
+
+// NewSlogObserver creates a SlogObserver that logs to logger
+func NewSlogObserver(logger *slog.Logger) *SlogObserver {
+	return &SlogObserver{logger: logger}
+}
+
+// OnRequest logs the request
+func (s *SlogObserver) OnRequest(e *RequestEvent) {
+	s.logger.LogAttrs(context.Background(), slog.LevelDebug,
+		"opentok request", requestAttrs(e)...)
+}
+
+// OnResponse logs the response status and latency
+func (s *SlogObserver) OnResponse(e *ResponseEvent) {
+	attrs := append(requestAttrs(&e.RequestEvent),
+		slog.Int("status", e.StatusCode),
+		slog.Duration("latency", e.Latency))
+	s.logger.LogAttrs(context.Background(), slog.LevelDebug,
+		"opentok response", attrs...)
+}
+
+// OnError logs the error with the token values redacted
+func (s *SlogObserver) OnError(e *ErrorEvent) {
+	attrs := append(requestAttrs(&e.RequestEvent),
+		slog.Int("status", e.StatusCode),
+		slog.Duration("latency", e.Latency),
+		slog.String("error", e.Message()))
+	s.logger.LogAttrs(context.Background(), slog.LevelError,
+		"opentok request failed", attrs...)
+}
+
+func requestAttrs(e *RequestEvent) []slog.Attr {
+	return []slog.Attr{
+		slog.Uint64("id", e.ID),
+		slog.String("operation", e.Operation),
+		slog.String("method", e.Method),
+		slog.String("endpoint", e.Endpoint),
+		slog.String("url", e.URL),
+	}
+}
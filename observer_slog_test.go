@@ -0,0 +1,36 @@
+//go:build go1.21
+
+package opentok
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestSlogObserver(t *testing.T) {
+	req := helpers.Session().Request(make(map[string]string))
+	res := helpers.Session().InvalidResponseNoAuth()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf,
+		&slog.HandlerOptions{Level: slog.LevelDebug}))
+	ot.AddObserver(NewSlogObserver(logger))
+
+	ot.Session(nil)
+
+	log := buf.String()
+	if !strings.Contains(log, "opentok request failed") ||
+		!strings.Contains(log, "status=403") {
+		t.Fatalf("Unexpected log: %s", log)
+	}
+	if strings.Contains(log, apiSecret) {
+		t.Fatalf("Log should not contain the secret: %s", log)
+	}
+}
@@ -0,0 +1,135 @@
+package opentok
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+type recordingObserver struct {
+	requests  []*RequestEvent
+	responses []*ResponseEvent
+	errors    []*ErrorEvent
+}
+
+func (r *recordingObserver) OnRequest(e *RequestEvent)   { r.requests = append(r.requests, e) }
+func (r *recordingObserver) OnResponse(e *ResponseEvent) { r.responses = append(r.responses, e) }
+func (r *recordingObserver) OnError(e *ErrorEvent)       { r.errors = append(r.errors, e) }
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   string
+	ended bool
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordingSpan) RecordError(message string)                 { s.err = message }
+func (s *recordingSpan) End()                                       { s.ended = true }
+
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(name string) Span {
+	span := &recordingSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestObserverResponse(t *testing.T) {
+	req := helpers.Archive().RequestStop(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Archive().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	observer := &recordingObserver{}
+	ot.AddObserver(observer)
+
+	if err := ot.ArchiveStop(archiveID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	if len(observer.requests) != 1 || len(observer.responses) != 1 {
+		t.Fatalf("Expected one request and one response: %d, %d",
+			len(observer.requests), len(observer.responses))
+	}
+	e := observer.responses[0]
+	if e.ID != observer.requests[0].ID {
+		t.Fatalf("Request and response should share the same id")
+	}
+	if e.Operation != "ArchiveStop" || e.Method != "POST" {
+		t.Fatalf("Unexpected operation: %s %s", e.Method, e.Operation)
+	}
+	if e.Endpoint != "/v2/partner/{apiKey}/archive/{archiveId}/stop" {
+		t.Fatalf("Unexpected endpoint: %s", e.Endpoint)
+	}
+	if e.StatusCode != 200 {
+		t.Fatalf("Unexpected status code: %d", e.StatusCode)
+	}
+	if auth := e.Header.Get("X-TB-PARTNER-AUTH"); auth != "REDACTED" {
+		t.Fatalf("X-TB-PARTNER-AUTH should be redacted: %s", auth)
+	}
+}
+
+func TestObserverError(t *testing.T) {
+	req := helpers.Session().Request(make(map[string]string))
+	res := helpers.Session().InvalidResponseNoAuth()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	observer := &recordingObserver{}
+	ot.AddObserver(observer)
+
+	if _, err := ot.Session(nil); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+
+	if len(observer.errors) != 1 || len(observer.responses) != 0 {
+		t.Fatalf("Expected one error and no responses: %d, %d",
+			len(observer.errors), len(observer.responses))
+	}
+	if e := observer.errors[0]; e.StatusCode != 403 || e.Err == nil {
+		t.Fatalf("Unexpected error event: %d %v", e.StatusCode, e.Err)
+	}
+}
+
+func TestRedactTokens(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+	token, _ := ot.Token(sessionID, nil)
+
+	message := redactTokens("invalid token: " + token.String())
+	if strings.Contains(message, token.String()) {
+		t.Fatalf("Token should be redacted: %s", message)
+	}
+	if message != "invalid token: T1==REDACTED" {
+		t.Fatalf("Unexpected message: %s", message)
+	}
+}
+
+func TestSpanObserver(t *testing.T) {
+	req := helpers.Archive().RequestGet(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Archive().ValidResponseWithArchive(helpers.Archive().DefaultParams())
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	tracer := &recordingTracer{}
+	ot.AddObserver(NewSpanObserver(tracer))
+
+	ot.ArchiveGet(archiveID)
+	ot.ArchiveGet("unknown")
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected two spans: %d", len(tracer.spans))
+	}
+	if span := tracer.spans[0]; span.name != "opentok.ArchiveGet" ||
+		!span.ended || span.attrs["http.status_code"] != 200 {
+		t.Fatalf("Unexpected span: %v", span)
+	}
+	if span := tracer.spans[1]; !span.ended || len(span.err) == 0 {
+		t.Fatalf("Span should have an error: %v", span)
+	}
+}
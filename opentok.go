@@ -6,13 +6,10 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -22,9 +19,12 @@ func New(apiKey int, apiSecret string) *OpenTok {
 	return &OpenTok{
 		APIKey:      apiKey,
 		APISecret:   apiSecret,
-		apiURL:      "https://api.opentok.com",
+		apiURL:      DefaultAPIURL,
 		partnerAuth: fmt.Sprintf("%d:%s", apiKey, apiSecret),
+		userAgent:   userAgent,
 		client:      &http.Client{},
+		clock:       systemClock{},
+		nonces:      cryptoNonces{},
 	}
 }
 
@@ -67,20 +67,22 @@ type OpenTok struct {
 	APISecret string
 
 	apiURL      string
+	regionHint  string
 	partnerAuth string
+	userAgent   string
 	client      httpClient
+	observers   []Observer
+	collector   Collector
+	clock       Clock
+	nonces      NonceSource
+	limiter     *rateLimiter
+	retry       RetryPolicy
 }
 
 // Session generates a new OpenTok Session. The Session.ID is
 // necessary for the clients to be able to connect to an
 // OpenTok Session
-func (ot *OpenTok) Session(props *SessionProps) (s *Session, err error) {
-	var (
-		req      *http.Request
-		res      *http.Response
-		sessions xmlSessions
-	)
-
+func (ot *OpenTok) Session(props *SessionProps) (*Session, error) {
 	if props == nil {
 		props = &SessionProps{}
 	}
@@ -89,37 +91,24 @@ func (ot *OpenTok) Session(props *SessionProps) (s *Session, err error) {
 	// been set or they are incorrect
 	defaultsSessionProps(props)
 
-	// prepare payload to be sent
-	propsMap := map[string]string{
-		"location":       props.Location,
-		"p2p.preference": string(props.MediaMode),
-		"archiveMode":    string(props.ArchiveMode),
+	location := props.Location
+	if len(location) == 0 {
+		location = ot.regionHint
 	}
-	payload := formURLEncode(propsMap)
-
-	// create request
-	if req, err = http.NewRequest("POST", ot.apiURL+"/session/create", payload); err != nil {
-		return nil, err
+	form := url.Values{
+		"location":       {location},
+		"p2p.preference": {string(props.MediaMode)},
+		"archiveMode":    {string(props.ArchiveMode)},
 	}
-	req.Header.Add("Content-type", "application/x-www-form-urlencoded")
-	ot.commonHeaders(&req.Header)
 
-	// perform request
-	if res, err = ot.client.Do(req); err != nil {
+	var sessions xmlSessions
+	if err := ot.execute(sessionCreate, nil, nil, form, &sessions); err != nil {
 		return nil, err
 	}
 
-	// check if request is correct
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, errFromStatusCode(res)
+	if len(sessions.Sessions) == 0 {
+		return nil, fmt.Errorf("Session create response has no session")
 	}
-
-	// read body response
-	if err = xml.NewDecoder(res.Body).Decode(&sessions); err != nil {
-		return nil, err
-	}
-
-	// get result
 	return &Session{
 		ID: sessions.Sessions[0].SessionID,
 	}, nil
@@ -137,7 +126,19 @@ func (ot *OpenTok) Token(sessionID string, props *TokenProps) (*Token, error) {
 		props = &TokenProps{}
 	}
 
-	key := calcKey(sessionID, props)
+	var err error
+	if ot.collector != nil {
+		start := time.Now()
+		ot.collector.Started(ot.APIKey, "Token")
+		defer func() {
+			ot.collector.Finished(ot.APIKey, "Token", 0, time.Since(start), err)
+		}()
+	}
+
+	key, err := ot.calcKey(sessionID, props)
+	if err != nil {
+		return nil, err
+	}
 	signature := ot.signKey(key)
 
 	buffer := bytes.NewBufferString("")
@@ -166,41 +167,12 @@ func (ot *OpenTok) ArchiveStart(sessionID string, props *ArchiveProps) (*Archive
 	if len(props.SessionID) == 0 {
 		props.SessionID = sessionID
 	}
-
-	var (
-		req     *http.Request
-		res     *http.Response
-		payload io.Reader
-		err     error
-	)
-
-	url := fmt.Sprintf("%s/v2/partner/%d/archive", ot.apiURL, ot.APIKey)
-
 	defaultArchiveProps(props)
-	if payload, err = jsonEncode(props); err != nil {
-		return nil, err
-	}
-	if req, err = http.NewRequest("POST", url, payload); err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("Content-type", "application/json")
-	ot.commonHeaders(&req.Header)
-	if res, err = ot.client.Do(req); err != nil {
-		return nil, err
-	}
-
-	// check that request status code is not an error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, errFromStatusCode(res)
-	}
 
 	var archive Archive
-	// read body response
-	if err = json.NewDecoder(res.Body).Decode(&archive); err != nil {
+	if err := ot.execute(archiveStart, nil, nil, props, &archive); err != nil {
 		return nil, err
 	}
-
 	return &archive, nil
 }
 
@@ -211,151 +183,277 @@ func (ot *OpenTok) ArchiveStop(archiveID string) error {
 	if len(archiveID) == 0 {
 		return fmt.Errorf("archiveID should not be empty")
 	}
+	return ot.execute(archiveStop, []string{archiveID}, nil, nil, nil)
+}
 
-	var (
-		req *http.Request
-		res *http.Response
-		err error
-	)
+// ArchiveGet retrieves an archive from the server. If the
+// archive does not exist an error will be raised
+func (ot *OpenTok) ArchiveGet(archiveID string) (*Archive, error) {
+	if len(archiveID) == 0 {
+		return nil, fmt.Errorf("ArchiveId is empty")
+	}
 
-	url := fmt.Sprintf("%s/v2/partner/%d/archive/%s/stop",
-		ot.apiURL, ot.APIKey, archiveID)
+	var archive Archive
+	if err := ot.execute(archiveGet, []string{archiveID}, nil, nil, &archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
 
-	if req, err = http.NewRequest("POST", url, nil); err != nil {
-		return err
+// ArchiveDelete deletes an existing archive with status available. If
+// the archive is in any other state the operation will
+// fail and return an error
+func (ot *OpenTok) ArchiveDelete(archiveID string) error {
+	if len(archiveID) == 0 {
+		return fmt.Errorf("ArchiveId is empty")
 	}
+	return ot.execute(archiveDelete, []string{archiveID}, nil, nil, nil)
+}
 
-	ot.commonHeaders(&req.Header)
-	if res, err = ot.client.Do(req); err != nil {
-		return err
+// ArchiveList returns a list of archives. If Count == 0, the limit of
+// the number of archives returned by the server is limited
+// by the server. Otherwise it will be count. Offset is
+// useful for pagination
+func (ot *OpenTok) ArchiveList(count, offset int) (*ArchiveList, error) {
+	query, err := pageQuery(count, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	// check that request status code is not an error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return errFromStatusCode(res)
+	var list ArchiveList
+	if err = ot.execute(archiveList, nil, query, nil, &list); err != nil {
+		return nil, err
 	}
+	return &list, nil
+}
 
-	return nil
+// SetArchiveStorage sets the storage account where the archives
+// of the project are uploaded once they are recorded. When an
+// upload target is set, archives reach status uploaded instead
+// of available
+func (ot *OpenTok) SetArchiveStorage(config StorageConfig) error {
+	defaultStorageConfig(&config)
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return ot.execute(archiveStorageSet, nil, nil, &config, nil)
 }
 
-// ArchiveGet retrieves an archive from the server. If the
-// archive does not exist an error will be raised
-func (ot *OpenTok) ArchiveGet(archiveID string) (*Archive, error) {
-	if len(archiveID) == 0 {
-		return nil, fmt.Errorf("ArchiveId is empty")
+// GetArchiveStorage retrieves the storage account where the
+// archives of the project are uploaded. If no upload target
+// has been set an error will be returned
+func (ot *OpenTok) GetArchiveStorage() (*StorageConfig, error) {
+	var config StorageConfig
+	if err := ot.execute(archiveStorageGet, nil, nil, nil, &config); err != nil {
+		return nil, err
 	}
+	return &config, nil
+}
 
-	var (
-		req     *http.Request
-		res     *http.Response
-		payload io.Reader
-		err     error
-	)
+// DeleteArchiveStorage removes the upload target of the project.
+// Archives recorded afterwards become available in the OpenTok
+// S3 account
+func (ot *OpenTok) DeleteArchiveStorage() error {
+	return ot.execute(archiveStorageDelete, nil, nil, nil, nil)
+}
+
+// RenderStart starts rendering the page of props.URL into the
+// session. As in ArchiveStart, sessionID is only used if
+// props.SessionID is empty. If props.Token is empty a publisher
+// token is generated for the render. props is not modified
+func (ot *OpenTok) RenderStart(sessionID string, props *RenderProps) (*Render, error) {
+	if props == nil {
+		return nil, fmt.Errorf("Render props should not be nil")
+	}
+	p := *props
+	if len(p.SessionID) == 0 {
+		p.SessionID = sessionID
+	}
+	if len(p.SessionID) == 0 {
+		return nil, fmt.Errorf("Session has empty id")
+	}
+	if len(p.URL) == 0 {
+		return nil, fmt.Errorf("Render url should not be empty")
+	}
 
-	url := fmt.Sprintf("%s/v2/partner/%d/archive/%s",
-		ot.apiURL, ot.APIKey, archiveID)
+	if len(p.Token) == 0 {
+		token, err := ot.Token(p.SessionID, &TokenProps{Role: Publisher})
+		if err != nil {
+			return nil, err
+		}
+		p.Token = token.String()
+	}
 
-	if req, err = http.NewRequest("GET", url, payload); err != nil {
+	var render Render
+	if err := ot.execute(renderStart, nil, nil, &p, &render); err != nil {
 		return nil, err
 	}
+	return &render, nil
+}
 
-	ot.commonHeaders(&req.Header)
-	if res, err = ot.client.Do(req); err != nil {
-		return nil, err
+// RenderStop stops a render. Its stream is removed from the
+// session
+func (ot *OpenTok) RenderStop(renderID string) error {
+	if len(renderID) == 0 {
+		return fmt.Errorf("renderID should not be empty")
 	}
+	return ot.execute(renderStop, []string{renderID}, nil, nil, nil)
+}
 
-	// check that request status code is not an error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, errFromStatusCode(res)
+// RenderGet retrieves a render from the server. If the render
+// does not exist an error will be returned
+func (ot *OpenTok) RenderGet(renderID string) (*Render, error) {
+	if len(renderID) == 0 {
+		return nil, fmt.Errorf("renderID should not be empty")
 	}
 
-	// read body response
-	var archive Archive
-	if err = json.NewDecoder(res.Body).Decode(&archive); err != nil {
+	var render Render
+	if err := ot.execute(renderGet, []string{renderID}, nil, nil, &render); err != nil {
 		return nil, err
 	}
-	return &archive, nil
+	return &render, nil
 }
 
-// ArchiveDelete deletes an existing archive with status available. If
-// the archive is in any other state the operation will
-// fail and return an error
-func (ot *OpenTok) ArchiveDelete(archiveID string) error {
-	if len(archiveID) == 0 {
-		return fmt.Errorf("ArchiveId is empty")
+// RenderList returns a list of renders. count and offset work
+// the same way as in ArchiveList
+func (ot *OpenTok) RenderList(count, offset int) (*RenderList, error) {
+	query, err := pageQuery(count, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	var (
-		req     *http.Request
-		res     *http.Response
-		payload io.Reader
-		err     error
-	)
+	var list RenderList
+	if err = ot.execute(renderList, nil, query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
 
-	url := fmt.Sprintf("%s/v2/partner/%d/archive/%s",
-		ot.apiURL, ot.APIKey, archiveID)
+// ConnectAudioToWebSocket starts an Audio Connector that streams
+// the audio of the session to the websocket of options. If token
+// is empty a publisher token is generated for the connector
+func (ot *OpenTok) ConnectAudioToWebSocket(sessionID, token string,
+	options WebSocketOptions) (*AudioConnection, error) {
 
-	if req, err = http.NewRequest("DELETE", url, payload); err != nil {
-		return err
+	if len(sessionID) == 0 {
+		return nil, fmt.Errorf("Session has empty id")
+	}
+	if !strings.HasPrefix(options.URI, "ws://") &&
+		!strings.HasPrefix(options.URI, "wss://") {
+		return nil, fmt.Errorf("websocket uri must start with ws:// or wss://: %s",
+			options.URI)
+	}
+	if options.AudioRate != 0 && options.AudioRate != 8000 &&
+		options.AudioRate != 16000 {
+		return nil, fmt.Errorf("audio rate must be 8000 or 16000: %d",
+			options.AudioRate)
 	}
 
-	ot.commonHeaders(&req.Header)
-	if res, err = ot.client.Do(req); err != nil {
-		return err
+	if len(token) == 0 {
+		t, err := ot.Token(sessionID, &TokenProps{Role: Publisher})
+		if err != nil {
+			return nil, err
+		}
+		token = t.String()
 	}
 
-	// check that request status code is not an error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return errFromStatusCode(res)
+	var connection AudioConnection
+	if err := ot.execute(audioConnect, nil, nil, &jsonConnectRequest{
+		SessionID: sessionID,
+		Token:     token,
+		WebSocket: options,
+	}, &connection); err != nil {
+		return nil, err
 	}
-	return nil
+	return &connection, nil
 }
 
-// ArchiveList returns a list of archives. If Count == 0, the limit of
-// the number of archives returned by the server is limited
-// by the server. Otherwise it will be count. Offset is
-// useful for pagination
-func (ot *OpenTok) ArchiveList(count, offset int) (*ArchiveList, error) {
-	if count < 0 {
-		return nil, fmt.Errorf("count must be bigger than 0: %d", count)
+// DisconnectWebSocket stops an Audio Connector. Its connection
+// leaves the session and the websocket is closed
+func (ot *OpenTok) DisconnectWebSocket(connectID string) error {
+	if len(connectID) == 0 {
+		return fmt.Errorf("connectID should not be empty")
 	}
-	if offset < 0 {
-		return nil, fmt.Errorf("offset must be bigger than or equal to0: %d",
-			offset)
+	return ot.execute(audioDisconnect, []string{connectID}, nil, nil, nil)
+}
+
+// CaptionsStart starts the live captions of the session and
+// returns the captions id. If token is empty a moderator token
+// is generated for the captions
+func (ot *OpenTok) CaptionsStart(sessionID, token string,
+	options CaptionOptions) (string, error) {
+
+	if len(sessionID) == 0 {
+		return "", fmt.Errorf("Session has empty id")
+	}
+	if options.MaxDuration != 0 &&
+		(options.MaxDuration < 300 || options.MaxDuration > 14400) {
+		return "", fmt.Errorf("max duration must be between 300 and 14400: %d",
+			options.MaxDuration)
+	}
+	if len(options.LanguageCode) == 0 {
+		options.LanguageCode = "en-US"
 	}
 
-	var (
-		req     *http.Request
-		res     *http.Response
-		payload io.Reader
-		err     error
-	)
+	if len(token) == 0 {
+		t, err := ot.Token(sessionID, &TokenProps{Role: Moderator})
+		if err != nil {
+			return "", err
+		}
+		token = t.String()
+	}
 
-	url := fmt.Sprintf("%s/v2/partner/%d/archive?offset=%d",
-		ot.apiURL, ot.APIKey, offset)
-	if count > 0 {
-		url = fmt.Sprintf("%s&count=%d", url, count)
+	var captions jsonCaptionsResponse
+	if err := ot.execute(captionsStart, nil, nil, &jsonCaptionsRequest{
+		SessionID:         sessionID,
+		Token:             token,
+		LanguageCode:      options.LanguageCode,
+		MaxDuration:       options.MaxDuration,
+		PartialCaptions:   options.PartialCaptions,
+		StatusCallbackURL: options.StatusCallbackURL,
+	}, &captions); err != nil {
+		return "", err
 	}
-	if req, err = http.NewRequest("GET", url, payload); err != nil {
-		return nil, err
+	return captions.CaptionsID, nil
+}
+
+// CaptionsStop stops the live captions with the given id
+func (ot *OpenTok) CaptionsStop(captionsID string) error {
+	if len(captionsID) == 0 {
+		return fmt.Errorf("captionsID should not be empty")
 	}
+	return ot.execute(captionsStop, []string{captionsID}, nil, nil, nil)
+}
 
-	ot.commonHeaders(&req.Header)
-	if res, err = ot.client.Do(req); err != nil {
+// ConnectionList returns the clients connected to the session.
+// count and offset work the same way as in ArchiveList
+func (ot *OpenTok) ConnectionList(sessionID string, count, offset int) (*ConnectionList, error) {
+	if len(sessionID) == 0 {
+		return nil, fmt.Errorf("Session has empty id")
+	}
+	query, err := pageQuery(count, offset)
+	if err != nil {
 		return nil, err
 	}
 
-	// check that request status code is not an error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, errFromStatusCode(res)
+	var list ConnectionList
+	if err = ot.execute(connectionList, []string{sessionID}, query, nil, &list); err != nil {
+		return nil, err
 	}
+	return &list, nil
+}
 
-	// read body response
-	var archiveList ArchiveList
-	if err = json.NewDecoder(res.Body).Decode(&archiveList); err != nil {
-		return nil, err
+// ForceDisconnect disconnects a client from the session. Its
+// streams are removed from the session
+func (ot *OpenTok) ForceDisconnect(sessionID, connectionID string) error {
+	if len(sessionID) == 0 {
+		return fmt.Errorf("Session has empty id")
 	}
-	return &archiveList, nil
+	if len(connectionID) == 0 {
+		return fmt.Errorf("connectionID should not be empty")
+	}
+	return ot.execute(connectionDelete, []string{sessionID, connectionID},
+		nil, nil, nil)
 }
 
 func (ot *OpenTok) signKey(key []byte) string {
@@ -364,14 +462,18 @@ func (ot *OpenTok) signKey(key []byte) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func calcKey(sessionID string, props *TokenProps) []byte {
+func (ot *OpenTok) calcKey(sessionID string, props *TokenProps) ([]byte, error) {
 	var (
-		createTime = time.Now().Unix()
-		nonce      = rand.Int31() % 1000000
+		createTime = ot.clock.Now().Unix()
 		role       = props.Role
 		expires    = props.ExpireTime
 	)
 
+	nonce, err := ot.nonces.Nonce()
+	if err != nil {
+		return nil, fmt.Errorf("Token nonce could not be generated: %s", err)
+	}
+
 	// Set role to Publisher if it hasn't been set by the client
 	// or if it has been set to an invalid value
 	if len(props.Role) == 0 ||
@@ -395,27 +497,7 @@ func calcKey(sessionID string, props *TokenProps) []byte {
 	if len(props.Data) > 0 && len(props.Data) < 1000 {
 		key.WriteString(fmt.Sprintf("&connection_data=%s", props.Data))
 	}
-	return key.Bytes()
-}
-
-func jsonEncode(data interface{}) (io.Reader, error) {
-	buf := bytes.NewBufferString("")
-	if err := json.NewEncoder(buf).Encode(data); err != nil {
-		return nil, err
-	}
-	return buf, nil
-}
-
-func formURLEncode(data map[string]string) io.Reader {
-	var params = url.Values{}
-
-	for key, value := range data {
-		params.Add(key, value)
-	}
-	encoded := params.Encode()
-	bufferString := bytes.NewBufferString(encoded)
-
-	return bytes.NewReader(bufferString.Bytes())
+	return key.Bytes(), nil
 }
 
 func defaultsSessionProps(props *SessionProps) {
@@ -437,20 +519,15 @@ func defaultArchiveProps(props *ArchiveProps) {
 	}
 }
 
-func errFromStatusCode(res *http.Response) error {
-	if res.ContentLength == 0 {
-		return fmt.Errorf("Error: statusCode: %d", res.StatusCode)
+func defaultStorageConfig(config *StorageConfig) {
+	if len(config.Fallback) == 0 ||
+		(config.Fallback != FallbackNone && config.Fallback != FallbackOpenTok) {
+		config.Fallback = FallbackNone
 	}
-
-	var message string
-	body := make([]byte, res.ContentLength)
-	res.Body.Read(body)
-	message = string(body)
-	return fmt.Errorf("Error: statusCode: %d, message: %s",
-		res.StatusCode, message)
 }
 
 func (ot *OpenTok) commonHeaders(h *http.Header) {
 	h.Add("X-TB-PARTNER-AUTH", ot.partnerAuth)
 	h.Add("X-TB-VERSION", "1")
+	h.Set("User-Agent", ot.userAgent)
 }
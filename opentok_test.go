@@ -1,6 +1,7 @@
 package opentok
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"regexp"
@@ -519,3 +520,607 @@ func TestArchiveList(t *testing.T) {
 			count, len(archiveList.Archives))
 	}
 }
+
+func TestSetArchiveStorageS3(t *testing.T) {
+	req := helpers.Storage().RequestSet(apiKey, map[string]interface{}{
+		"type": "s3",
+		"config": map[string]interface{}{
+			"accessKey": "accessKey",
+			"bucket":    "bucket",
+			"endpoint":  "https://s3.example.com",
+			"secretKey": "secretKey",
+		},
+		"fallback": "none",
+	}).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Storage().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	err := ot.SetArchiveStorage(StorageConfig{
+		Type: S3,
+		S3: &S3Config{
+			AccessKey: "accessKey",
+			Bucket:    "bucket",
+			Endpoint:  "https://s3.example.com",
+			SecretKey: "secretKey",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func TestSetArchiveStorageAzure(t *testing.T) {
+	req := helpers.Storage().RequestSet(apiKey, map[string]interface{}{
+		"type": "azure",
+		"config": map[string]interface{}{
+			"accountKey":  "accountKey",
+			"accountName": "accountName",
+			"container":   "container",
+		},
+		"fallback": "opentok",
+	}).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Storage().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	err := ot.SetArchiveStorage(StorageConfig{
+		Type: Azure,
+		Azure: &AzureConfig{
+			AccountKey:  "accountKey",
+			AccountName: "accountName",
+			Container:   "container",
+		},
+		Fallback: FallbackOpenTok,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func TestSetArchiveStorageFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	if err := ot.SetArchiveStorage(StorageConfig{Type: S3}); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	err := ot.SetArchiveStorage(StorageConfig{
+		Type: Azure,
+		S3:   &S3Config{Bucket: "bucket"},
+	})
+	if err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestGetArchiveStorage(t *testing.T) {
+	req := helpers.Storage().RequestGet(apiKey).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Storage().ValidResponseWithS3("accessKey", "bucket",
+		"secretKey", "opentok")
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	config, err := ot.GetArchiveStorage()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if config.Type != S3 || config.S3 == nil {
+		t.Fatalf("Unexpected storage config: %v", config)
+	}
+	if config.S3.Bucket != "bucket" {
+		t.Fatalf("Unexpected bucket: expected: %s, received: %s",
+			"bucket", config.S3.Bucket)
+	}
+	if config.Fallback != FallbackOpenTok {
+		t.Fatalf("Unexpected fallback: expected: %s, received: %s",
+			FallbackOpenTok, config.Fallback)
+	}
+}
+
+func TestDeleteArchiveStorage(t *testing.T) {
+	req := helpers.Storage().RequestDelete(apiKey).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Storage().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.DeleteArchiveStorage(); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func TestRenderStart(t *testing.T) {
+	body := map[string]interface{}{
+		"maxDuration": 1800,
+		"properties":  map[string]interface{}{"name": "whiteboard"},
+		"resolution":  "1280x720",
+		"sessionId":   sessionID,
+		"token":       "T1==token",
+		"url":         "https://example.com/whiteboard",
+	}
+	req := helpers.Render().RequestStart(apiKey, body).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	params := helpers.Render().DefaultParams()
+	res := helpers.Render().ValidResponseWithRender(params)
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	render, err := ot.RenderStart(sessionID, &RenderProps{
+		URL:         "https://example.com/whiteboard",
+		Token:       "T1==token",
+		MaxDuration: 1800,
+		Resolution:  "1280x720",
+		Properties:  &RenderProperties{Name: "whiteboard"},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if render.ID != params.ID || render.Status != "started" {
+		t.Fatalf("Unexpected render: %+v", render)
+	}
+}
+
+func TestRenderStartGeneratesToken(t *testing.T) {
+	clock := ClockFunc(func() time.Time { return time.Unix(1435484890, 0) })
+	nonces := NonceSourceFunc(func() (int64, error) { return 424242, nil })
+
+	generator := New(apiKey, apiSecret)
+	generator.SetClock(clock)
+	generator.SetNonceSource(nonces)
+	token, _ := generator.Token(sessionID, &TokenProps{Role: Publisher})
+
+	body := map[string]interface{}{
+		"sessionId": sessionID,
+		"token":     token.String(),
+		"url":       "https://example.com/whiteboard",
+	}
+	req := helpers.Render().RequestStart(apiKey, body).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Render().ValidResponseWithRender(helpers.Render().DefaultParams())
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetClock(clock)
+	ot.SetNonceSource(nonces)
+
+	if _, err := ot.RenderStart(sessionID, &RenderProps{
+		URL: "https://example.com/whiteboard",
+	}); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func TestRenderStartReusedProps(t *testing.T) {
+	client, requests := respondInSequence(200, 200)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	props := &RenderProps{URL: "https://example.com/whiteboard"}
+	for _, id := range []string{"sessionA", "sessionB"} {
+		if _, err := ot.RenderStart(id, props); err != nil {
+			t.Fatalf("Expected err to be nil: %s", err)
+		}
+	}
+	if len(props.SessionID) > 0 || len(props.Token) > 0 {
+		t.Fatalf("The props should not be modified: %+v", props)
+	}
+
+	var body RenderProps
+	json.NewDecoder((*requests)[1].Body).Decode(&body)
+	info, err := DecodeToken(body.Token)
+	if err != nil || body.SessionID != "sessionB" || info.SessionID != "sessionB" {
+		t.Fatalf("Unexpected request: %+v %v", body, err)
+	}
+}
+
+func TestRenderStartPropsSession(t *testing.T) {
+	client, requests := respondInSequence(200)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	// props.SessionID takes precedence, as in ArchiveStart
+	props := &RenderProps{URL: "https://example.com/whiteboard", SessionID: "sessionA"}
+	if _, err := ot.RenderStart("sessionB", props); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	var body RenderProps
+	json.NewDecoder((*requests)[0].Body).Decode(&body)
+	if body.SessionID != "sessionA" {
+		t.Fatalf("Unexpected session: %s", body.SessionID)
+	}
+}
+
+func TestRenderStartFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	if _, err := ot.RenderStart(sessionID, nil); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if _, err := ot.RenderStart("", &RenderProps{URL: "https://example.com"}); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if _, err := ot.RenderStart(sessionID, &RenderProps{}); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestRenderStop(t *testing.T) {
+	req := helpers.Render().RequestStop(apiKey, "renderId").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Render().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.RenderStop("renderId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.RenderStop(""); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestRenderGet(t *testing.T) {
+	req := helpers.Render().RequestGet(apiKey, "renderId").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	params := helpers.Render().DefaultParams()
+	res := helpers.Render().ValidResponseWithRender(params)
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	render, err := ot.RenderGet("renderId")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if render.ID != params.ID || render.URL != params.URL ||
+		render.APIKey != params.APIKey {
+		t.Fatalf("Unexpected render: %+v", render)
+	}
+}
+
+func TestRenderList(t *testing.T) {
+	req := helpers.Render().RequestList(apiKey, 2, 0).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	params := helpers.Render().DefaultParams()
+	res := helpers.Render().ValidResponseWithRenders(5, params, params)
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	renderList, err := ot.RenderList(2, 0)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if renderList.Count != 5 || len(renderList.Renders) != 2 {
+		t.Fatalf("Unexpected render list: %+v", renderList)
+	}
+}
+
+func TestConnectAudioToWebSocket(t *testing.T) {
+	body := map[string]interface{}{
+		"sessionId": sessionID,
+		"token":     "T1==token",
+		"websocket": map[string]interface{}{
+			"audioRate":     16000,
+			"bidirectional": true,
+			"headers":       map[string]string{"language": "en"},
+			"streams":       []string{"streamId"},
+			"uri":           "wss://example.com/audio",
+		},
+	}
+	req := helpers.Connect().RequestConnect(apiKey, body).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Connect().ValidResponse("connectId", "connectionId")
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	connection, err := ot.ConnectAudioToWebSocket(sessionID, "T1==token",
+		WebSocketOptions{
+			URI:           "wss://example.com/audio",
+			Streams:       []string{"streamId"},
+			Headers:       map[string]string{"language": "en"},
+			AudioRate:     16000,
+			Bidirectional: true,
+		})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if connection.ID != "connectId" || connection.ConnectionID != "connectionId" {
+		t.Fatalf("Unexpected connection: %+v", connection)
+	}
+}
+
+func TestConnectAudioToWebSocketFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	options := WebSocketOptions{URI: "wss://example.com/audio"}
+	if _, err := ot.ConnectAudioToWebSocket("", "", options); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	options = WebSocketOptions{URI: "https://example.com/audio"}
+	if _, err := ot.ConnectAudioToWebSocket(sessionID, "", options); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	options = WebSocketOptions{URI: "wss://example.com/audio", AudioRate: 44100}
+	if _, err := ot.ConnectAudioToWebSocket(sessionID, "", options); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestDisconnectWebSocket(t *testing.T) {
+	req := helpers.Connect().RequestDisconnect(apiKey, "connectId").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Connect().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.DisconnectWebSocket("connectId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.DisconnectWebSocket(""); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestCaptionsStart(t *testing.T) {
+	body := map[string]interface{}{
+		"languageCode":      "en-US",
+		"maxDuration":       1800,
+		"partialCaptions":   true,
+		"sessionId":         sessionID,
+		"statusCallbackUrl": "https://example.com/captions",
+		"token":             "T1==token",
+	}
+	req := helpers.Captions().RequestStart(apiKey, body).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Captions().ValidResponse("captionsId")
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	captionsID, err := ot.CaptionsStart(sessionID, "T1==token", CaptionOptions{
+		MaxDuration:       1800,
+		PartialCaptions:   true,
+		StatusCallbackURL: "https://example.com/captions",
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if captionsID != "captionsId" {
+		t.Fatalf("Unexpected captions id: %s", captionsID)
+	}
+}
+
+func TestCaptionsStartFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	if _, err := ot.CaptionsStart("", "", CaptionOptions{}); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	options := CaptionOptions{MaxDuration: 60}
+	if _, err := ot.CaptionsStart(sessionID, "", options); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestCaptionsStop(t *testing.T) {
+	req := helpers.Captions().RequestStop(apiKey, "captionsId").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Captions().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.CaptionsStop("captionsId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.CaptionsStop(""); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestConnectionList(t *testing.T) {
+	req := helpers.Connection().RequestList(apiKey, sessionID, 2, 2).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Connection().ValidResponseWithConnections(apiKey, sessionID, 4,
+		&helpers.ConnectionParams{ID: "connection2", State: "Connected", Data: "name=John"},
+		&helpers.ConnectionParams{ID: "connection3", State: "Connecting"})
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	connectionList, err := ot.ConnectionList(sessionID, 2, 2)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if connectionList.Count != 4 || len(connectionList.Connections) != 2 {
+		t.Fatalf("Unexpected connection list: %+v", connectionList)
+	}
+	connection := connectionList.Connections[0]
+	if connection.ID != "connection2" || connection.State != "Connected" ||
+		connection.Data != "name=John" || connection.CreatedAt == 0 {
+		t.Fatalf("Unexpected connection: %+v", connection)
+	}
+}
+
+func TestConnectionListTokenData(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+	data := `{"name":"John & Jane","id":1}`
+	token, err := ot.Token(sessionID, &TokenProps{Data: data})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	info, err := DecodeToken(token.String())
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	// the platform returns the connection data of the token
+	req := helpers.Connection().RequestList(apiKey, sessionID, 1, 0).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Connection().ValidResponseWithConnections(apiKey, sessionID, 1,
+		&helpers.ConnectionParams{ID: "connection1", State: "Connected", Data: info.Data})
+	ot.client = helpers.NewClient().Add(req, res)
+
+	list, err := ot.ConnectionList(sessionID, 1, 0)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if list.Connections[0].Data != data || info.Data != data {
+		t.Fatalf("Unexpected connection data: %q, token %q", list.Connections[0].Data,
+			info.Data)
+	}
+}
+
+func TestConnectionListFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	if _, err := ot.ConnectionList("", 0, 0); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if _, err := ot.ConnectionList(sessionID, -1, 0); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestForceDisconnect(t *testing.T) {
+	req := helpers.Connection().RequestDelete(apiKey, sessionID, "connectionId").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	res := helpers.Connection().ValidResponseEmpty()
+	client := helpers.NewClient().
+		Add(req, res)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if err := ot.ForceDisconnect(sessionID, "connectionId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if err := ot.ForceDisconnect(sessionID, ""); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestDecodeToken(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+
+	expireTime := time.Now().Unix() + 60
+	token, _ := ot.Token(sessionID, &TokenProps{
+		ExpireTime: expireTime,
+		Data:       "name=John&id=1",
+		Role:       Moderator,
+	})
+
+	info, err := DecodeToken(token.String())
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if info.APIKey != apiKey {
+		t.Fatalf("Invalid apiKey in token: %d, expected %d",
+			info.APIKey, apiKey)
+	}
+	if info.SessionID != sessionID {
+		t.Fatalf("Invalid session_id in token: %s, expected %s",
+			info.SessionID, sessionID)
+	}
+	if info.Role != Moderator {
+		t.Fatalf("Invalid role in token: %s, expected %s",
+			info.Role, Moderator)
+	}
+	if info.ExpireTime != expireTime {
+		t.Fatalf("Invalid expireTime in token: %d, expected %d",
+			info.ExpireTime, expireTime)
+	}
+	if info.Data != "name=John&id=1" {
+		t.Fatalf("Invalid connectionData in token: %s", info.Data)
+	}
+}
+
+func TestDecodeTokenFails(t *testing.T) {
+	for _, token := range []string{"", "T1==", "T2==abc", "T1==!!!"} {
+		if _, err := DecodeToken(token); err == nil {
+			t.Fatalf("Expected err not to be nil for token %q", token)
+		}
+	}
+}
+
+func TestSessionAPIKey(t *testing.T) {
+	key, err := SessionAPIKey(helpers.Session().ID(apiKey))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if key != apiKey {
+		t.Fatalf("Unexpected apiKey: expected: %d, received: %d",
+			apiKey, key)
+	}
+
+	if _, err := SessionAPIKey(sessionID); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestTokenDeterministic(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+	ot.SetClock(ClockFunc(func() time.Time {
+		return time.Unix(1435484890, 0)
+	}))
+	ot.SetNonceSource(NonceSourceFunc(func() (int64, error) {
+		return 424242, nil
+	}))
+
+	token, err := ot.Token(sessionID, &TokenProps{
+		Role:       Moderator,
+		ExpireTime: 1435488490,
+		Data:       "name=John",
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	expected := "T1==cGFydG5lcl9pZD0xMjM0NTYmc2lnPTYwZWU2ZTNjNTI4Njc0NTMyZTJmMTMxMzQwNmZm" +
+		"OTc5ZDRiMjI3YmU6c2Vzc2lvbl9pZD1zZXNzaW9uSWQmY3JlYXRlX3RpbWU9MTQzNTQ4NDg5" +
+		"MCZub25jZT00MjQyNDImcm9sZT1tb2RlcmF0b3ImZXhwaXJlX3RpbWU9MTQzNTQ4ODQ5MCZj" +
+		"b25uZWN0aW9uX2RhdGE9bmFtZT1Kb2hu"
+	if token.String() != expected {
+		t.Fatalf("Unexpected token: %s, expected %s", token, expected)
+	}
+}
+
+func TestTokenDefaultExpireTime(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+	now := time.Unix(1435484890, 0)
+	ot.SetClock(ClockFunc(func() time.Time { return now }))
+
+	token, _ := ot.Token(sessionID, nil)
+	info, _ := DecodeToken(token.String())
+
+	if info.CreateTime != now.Unix() {
+		t.Fatalf("Invalid createTime in token: %d, expected %d",
+			info.CreateTime, now.Unix())
+	}
+	if info.ExpireTime != now.Unix()+60*60*24 {
+		t.Fatalf("Invalid expireTime in token: %d, expected %d",
+			info.ExpireTime, now.Unix()+60*60*24)
+	}
+	if info.Nonce < 0 || info.Nonce > 999999 {
+		t.Fatalf("Invalid nonce in token: %d", info.Nonce)
+	}
+}
+
+func TestTokenNonceFails(t *testing.T) {
+	ot := New(apiKey, apiSecret)
+	ot.SetNonceSource(NonceSourceFunc(func() (int64, error) {
+		return 0, fmt.Errorf("no entropy")
+	}))
+
+	if _, err := ot.Token(sessionID, nil); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
@@ -0,0 +1,46 @@
+package opentok
+
+import (
+	"context"
+	"sync"
+)
+
+// parallel calls fn for every index in [0, count) with up to
+// concurrency calls running at the same time. Once ctx is done
+// no more calls are started, and the indexes that were not
+// handled are returned
+func parallel(ctx context.Context, concurrency, count int, fn func(i int)) []int {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, concurrency)
+		skipped []int
+	)
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		// ctx may be done even if a slot was free
+		if ctx.Err() != nil {
+			for j := i; j < count; j++ {
+				skipped = append(skipped, j)
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return skipped
+}
@@ -0,0 +1,241 @@
+package opentok
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionPool keeps sessions created in advance so they can be
+// handed out without waiting for the OpenTok platform. Sessions
+// are grouped by the SessionProps they were created with, and
+// every group is refilled in the background when a session is
+// taken. It implements SessionCreator, so it can be used by a
+// RoomManager
+type SessionPool struct {
+	creator SessionCreator
+	size    int
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	profiles map[SessionProps]*poolProfile
+	closed   bool
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type poolProfile struct {
+	sessions []pooledSession
+	filling  bool
+	err      error
+}
+
+type pooledSession struct {
+	session *Session
+	created time.Time
+}
+
+// NewSessionPool creates a SessionPool that keeps size sessions
+// for every profile, created with creator. Sessions that are not
+// used within ttl are discarded and replaced. A ttl of 0 keeps
+// the sessions until they are used
+func NewSessionPool(creator SessionCreator, size int, ttl time.Duration) *SessionPool {
+	p := &SessionPool{
+		creator:  creator,
+		size:     size,
+		ttl:      ttl,
+		profiles: make(map[SessionProps]*poolProfile),
+		stop:     make(chan struct{}),
+	}
+
+	if ttl > 0 {
+		p.wg.Add(1)
+		go p.expire()
+	}
+	return p
+}
+
+// Warm starts filling the pool for the profile described by
+// props. The sessions are created in the background
+func (p *SessionPool) Warm(props SessionProps) {
+	defaultsSessionProps(&props)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.refill(props, p.profile(props))
+}
+
+// Session returns a session created with props from the pool.
+// If the pool for the profile is empty, the session is created
+// right away and the pool starts filling for the next calls
+func (p *SessionPool) Session(props *SessionProps) (*Session, error) {
+	key := SessionProps{}
+	if props != nil {
+		key = *props
+	}
+	defaultsSessionProps(&key)
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("SessionPool is closed")
+	}
+	profile := p.profile(key)
+	p.discardExpired(profile, time.Now())
+
+	var session *Session
+	if n := len(profile.sessions); n > 0 {
+		// hand out the newest session, which is the furthest
+		// away from expiring
+		session = profile.sessions[n-1].session
+		profile.sessions = profile.sessions[:n-1]
+	}
+	p.refill(key, profile)
+	p.mu.Unlock()
+
+	if session != nil {
+		return session, nil
+	}
+	return p.creator.Session(&key)
+}
+
+// Len returns the number of sessions ready for the profile
+// described by props
+func (p *SessionPool) Len(props SessionProps) int {
+	defaultsSessionProps(&props)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if profile, ok := p.profiles[props]; ok {
+		return len(profile.sessions)
+	}
+	return 0
+}
+
+// Err returns the last error found while filling the pool for
+// the profile described by props
+func (p *SessionPool) Err(props SessionProps) error {
+	defaultsSessionProps(&props)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if profile, ok := p.profiles[props]; ok {
+		return profile.err
+	}
+	return nil
+}
+
+// Close stops filling the pool and waits for the sessions being
+// created. Session fails after Close has been called
+func (p *SessionPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// profile returns the profile for key, creating it if needed.
+// p.mu must be held
+func (p *SessionPool) profile(key SessionProps) *poolProfile {
+	profile, ok := p.profiles[key]
+	if !ok {
+		profile = &poolProfile{}
+		p.profiles[key] = profile
+	}
+	return profile
+}
+
+// refill starts filling profile in the background unless it is
+// already full or being filled. p.mu must be held
+func (p *SessionPool) refill(key SessionProps, profile *poolProfile) {
+	if p.closed || profile.filling || len(profile.sessions) >= p.size {
+		return
+	}
+	profile.filling = true
+	p.wg.Add(1)
+	go p.fill(key, profile)
+}
+
+// fill creates sessions until the profile is full. If a session
+// cannot be created it stops and keeps the error, and the next
+// call to Session will try again
+func (p *SessionPool) fill(key SessionProps, profile *poolProfile) {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		if p.closed || len(profile.sessions) >= p.size {
+			profile.filling = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+
+		props := key
+		session, err := p.creator.Session(&props)
+
+		p.mu.Lock()
+		profile.err = err
+		if err != nil {
+			profile.filling = false
+			p.mu.Unlock()
+			return
+		}
+		profile.sessions = append(profile.sessions, pooledSession{
+			session: session,
+			created: time.Now(),
+		})
+		p.mu.Unlock()
+	}
+}
+
+// expire periodically discards the sessions older than the ttl
+// and refills the pool
+func (p *SessionPool) expire() {
+	defer p.wg.Done()
+
+	// NewTicker panics with an interval of 0, e.g. for a ttl of
+	// 1ns, and tiny intervals would only spin
+	interval := p.ttl / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			for key, profile := range p.profiles {
+				p.discardExpired(profile, now)
+				p.refill(key, profile)
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// discardExpired removes the sessions older than the ttl. The
+// sessions are kept in creation order. p.mu must be held
+func (p *SessionPool) discardExpired(profile *poolProfile, now time.Time) {
+	if p.ttl <= 0 {
+		return
+	}
+	i := 0
+	for i < len(profile.sessions) && now.Sub(profile.sessions[i].created) >= p.ttl {
+		i++
+	}
+	profile.sessions = profile.sessions[i:]
+}
@@ -0,0 +1,109 @@
+package opentok
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it is true or the timeout expires
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met before the timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSessionPool(t *testing.T) {
+	creator := &countingCreator{}
+	pool := NewSessionPool(creator, 3, 0)
+	defer pool.Close()
+
+	props := SessionProps{MediaMode: Relayed}
+	pool.Warm(props)
+	waitFor(t, func() bool { return pool.Len(props) == 3 })
+
+	session, err := pool.Session(&props)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if session == nil {
+		t.Fatalf("Session should not be nil")
+	}
+	waitFor(t, func() bool { return pool.Len(props) == 3 })
+
+	if creator.count() != 4 {
+		t.Fatalf("Expected four sessions to be created: %d", creator.count())
+	}
+	for _, created := range creator.props {
+		if created.MediaMode != Relayed {
+			t.Fatalf("Sessions should be created with the profile: %v", created)
+		}
+	}
+}
+
+func TestSessionPoolEmpty(t *testing.T) {
+	creator := &countingCreator{}
+	pool := NewSessionPool(creator, 2, 0)
+	defer pool.Close()
+
+	session, err := pool.Session(nil)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if session == nil {
+		t.Fatalf("Session should not be nil")
+	}
+
+	// the default profile starts filling after the first call
+	waitFor(t, func() bool { return pool.Len(SessionProps{}) == 2 })
+}
+
+func TestSessionPoolExpire(t *testing.T) {
+	creator := &countingCreator{}
+	pool := NewSessionPool(creator, 1, 40*time.Millisecond)
+	defer pool.Close()
+
+	pool.Warm(SessionProps{})
+	waitFor(t, func() bool { return pool.Len(SessionProps{}) == 1 })
+
+	// the unused session is replaced once it expires
+	waitFor(t, func() bool { return creator.count() >= 2 })
+}
+
+func TestSessionPoolTinyTTL(t *testing.T) {
+	// a ttl of 1ns must not make the expiration ticker panic
+	pool := NewSessionPool(&countingCreator{}, 1, time.Nanosecond)
+	time.Sleep(5 * time.Millisecond)
+	pool.Close()
+}
+
+func TestSessionPoolError(t *testing.T) {
+	pool := NewSessionPool(failingCreator{}, 1, 0)
+	defer pool.Close()
+
+	pool.Warm(SessionProps{})
+	waitFor(t, func() bool { return pool.Err(SessionProps{}) != nil })
+
+	if _, err := pool.Session(nil); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestSessionPoolClosed(t *testing.T) {
+	pool := NewSessionPool(&countingCreator{}, 1, time.Second)
+	pool.Close()
+
+	if _, err := pool.Session(nil); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+type failingCreator struct{}
+
+func (failingCreator) Session(props *SessionProps) (*Session, error) {
+	return nil, fmt.Errorf("Error: statusCode: 500")
+}
@@ -0,0 +1,154 @@
+package opentok
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a call exceeds the rate limit
+// set with SetRateLimit and the limit does not wait
+var ErrRateLimited = errors.New("OpenTok rate limit exceeded")
+
+// RateLimit configures the client side rate limiting of an
+// OpenTok object. Sessions and archive operations have separate
+// token buckets. A rate of 0 does not limit the operations
+type RateLimit struct {
+	// SessionRate is the number of sessions per second that can
+	// be created, with bursts of up to SessionBurst sessions
+	SessionRate  float64
+	SessionBurst int
+
+	// ArchiveRate is the number of archive operations per second
+	// that can be made, with bursts of up to ArchiveBurst calls
+	ArchiveRate  float64
+	ArchiveBurst int
+
+	// Wait blocks the calls until the limit allows them. If it
+	// is false the calls fail right away with ErrRateLimited
+	Wait bool
+}
+
+// SetRateLimit limits the calls made by the OpenTok object.
+// When the platform answers 429 Too Many Requests, the calls of
+// the same kind are paused for the time in the Retry-After
+// header. It must be called before the OpenTok object is used
+func (ot *OpenTok) SetRateLimit(l RateLimit) {
+	ot.limiter = newRateLimiter(l)
+}
+
+const (
+	sessionCalls = "session"
+	archiveCalls = "archive"
+)
+
+// callKind returns the bucket used by operation, or an empty
+// string if the operation is not limited
+func callKind(operation string) string {
+	switch operation {
+	case "Session":
+		return sessionCalls
+	case "ArchiveStart", "ArchiveStop", "ArchiveGet", "ArchiveDelete",
+		"ArchiveList":
+		return archiveCalls
+	}
+	return ""
+}
+
+type rateLimiter struct {
+	wait bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	paused  map[string]time.Time
+}
+
+func newRateLimiter(l RateLimit) *rateLimiter {
+	now := time.Now()
+	r := &rateLimiter{
+		wait:    l.Wait,
+		buckets: make(map[string]*tokenBucket),
+		paused:  make(map[string]time.Time),
+	}
+	if l.SessionRate > 0 {
+		r.buckets[sessionCalls] = newTokenBucket(l.SessionRate,
+			maxInt(l.SessionBurst, 1), now)
+	}
+	if l.ArchiveRate > 0 {
+		r.buckets[archiveCalls] = newTokenBucket(l.ArchiveRate,
+			maxInt(l.ArchiveBurst, 1), now)
+	}
+	return r
+}
+
+// acquire takes a token for operation, waiting for it if the
+// limiter waits
+func (r *rateLimiter) acquire(operation string) error {
+	kind := callKind(operation)
+	for {
+		delay := r.reserve(kind, time.Now())
+		if delay <= 0 {
+			return nil
+		}
+		if !r.wait {
+			return ErrRateLimited
+		}
+		time.Sleep(delay)
+	}
+}
+
+func (r *rateLimiter) reserve(kind string, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until, ok := r.paused[kind]; ok {
+		if now.Before(until) {
+			return until.Sub(now)
+		}
+		delete(r.paused, kind)
+	}
+
+	b, ok := r.buckets[kind]
+	if !ok {
+		return 0
+	}
+	return b.take(now)
+}
+
+// throttle pauses the calls of the same kind as operation after
+// the platform answered 429 Too Many Requests
+func (r *rateLimiter) throttle(operation string, res *http.Response) {
+	kind := callKind(operation)
+	if len(kind) == 0 {
+		return
+	}
+
+	now := time.Now()
+	pause := time.Second
+	if retryAfter := res.Header.Get("Retry-After"); len(retryAfter) > 0 {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			pause = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(retryAfter); err == nil {
+			pause = t.Sub(now)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until := now.Add(pause); until.After(r.paused[kind]) {
+		r.paused[kind] = until
+	}
+	if b, ok := r.buckets[kind]; ok {
+		b.empty(now)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,89 @@
+package opentok
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestRateLimitFailFast(t *testing.T) {
+	client := helpers.NewClient()
+	client.SetDefaultResponse(helpers.Archive().ValidResponseEmpty())
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRateLimit(RateLimit{
+		ArchiveRate:  1,
+		ArchiveBurst: 2,
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := ot.ArchiveStop("archive"); err != nil {
+			t.Fatalf("Expected err to be nil: %s", err)
+		}
+	}
+	if err := ot.ArchiveStop("archive"); err != ErrRateLimited {
+		t.Fatalf("Expected ErrRateLimited: %v", err)
+	}
+
+	// sessions have their own bucket, which is not limited
+	req := helpers.Session().Request(make(map[string]string)).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	client.Add(req, helpers.Session().ValidResponse("sessionID", apiKey))
+	if _, err := ot.Session(nil); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	client := helpers.NewClient()
+	client.SetDefaultResponse(helpers.Archive().ValidResponseEmpty())
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRateLimit(RateLimit{
+		ArchiveRate: 20,
+		Wait:        true,
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := ot.ArchiveStop("archive"); err != nil {
+			t.Fatalf("Expected err to be nil: %s", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("Expected the calls to wait for the limit: %s", elapsed)
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	client := helpers.NewClient()
+	client.SetDefaultResponse(helpers.NewResponse(429).AddHeader("Retry-After", "30"))
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	ot.SetRateLimit(RateLimit{
+		ArchiveRate:  100,
+		ArchiveBurst: 100,
+	})
+
+	err := ot.ArchiveStop("archive")
+	if err == nil || err == ErrRateLimited {
+		t.Fatalf("Expected the error of the platform: %v", err)
+	}
+	if err = ot.ArchiveDelete("archive"); err != ErrRateLimited {
+		t.Fatalf("Expected the archive calls to be paused: %v", err)
+	}
+}
+
+func TestRateLimitCallKind(t *testing.T) {
+	for operation, expected := range map[string]string{
+		"Session":              sessionCalls,
+		"ArchiveStop":          archiveCalls,
+		"ArchiveList":          archiveCalls,
+		"SetArchiveStorage":    "",
+		"GetArchiveStorage":    "",
+		"DeleteArchiveStorage": "",
+		"RenderStart":          "",
+	} {
+		if kind := callKind(operation); kind != expected {
+			t.Fatalf("Unexpected kind of %s: %q", operation, kind)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package opentok
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultAPIURL is the URL of the OpenTok platform used unless
+// another one is set with SetAPIURL
+const DefaultAPIURL = "https://api.opentok.com"
+
+// NewWithAPIURL creates a new OpenTok object that sends its
+// requests to apiURL, e.g. a regional or enterprise endpoint or a
+// local stand-in of the platform for testing
+func NewWithAPIURL(apiKey int, apiSecret, apiURL string) (*OpenTok, error) {
+	ot := New(apiKey, apiSecret)
+	if err := ot.SetAPIURL(apiURL); err != nil {
+		return nil, err
+	}
+	return ot, nil
+}
+
+// SetAPIURL sets the URL the requests are sent to. It must be an
+// http or https URL without a query. Trailing slashes are removed.
+// It must be called before the OpenTok object is used
+func (ot *OpenTok) SetAPIURL(apiURL string) error {
+	normalized, err := normalizeAPIURL(apiURL)
+	if err != nil {
+		return err
+	}
+	ot.apiURL = normalized
+	return nil
+}
+
+// APIURL returns the URL the requests are sent to
+func (ot *OpenTok) APIURL() string {
+	return ot.apiURL
+}
+
+// SetRegionHint sets the location used to create the sessions
+// whose SessionProps.Location is empty. location is an IP address
+// in the region where the media should be hosted, e.g. the
+// address of a data center of the tenant. An empty location
+// removes the hint
+func (ot *OpenTok) SetRegionHint(location string) error {
+	if len(location) > 0 && net.ParseIP(location) == nil {
+		return fmt.Errorf("region hint must be an IP address: %s", location)
+	}
+	ot.regionHint = location
+	return nil
+}
+
+func normalizeAPIURL(apiURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(apiURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid api url: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("api url must start with http:// or https://: %s",
+			apiURL)
+	}
+	if len(u.Host) == 0 {
+		return "", fmt.Errorf("api url has no host: %s", apiURL)
+	}
+	if u.User != nil || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+		return "", fmt.Errorf("api url must not have credentials, a query "+
+			"or a fragment: %s", apiURL)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
@@ -0,0 +1,234 @@
+package opentok
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Credential is the API key and API secret of an OpenTok project
+type Credential struct {
+	APIKey    int    `json:"apiKey"`
+	APISecret string `json:"apiSecret"`
+}
+
+// CredentialSource provides the credentials of all the projects
+// that a Registry manages. Credentials is called every time the
+// Registry is reloaded, so it must return the current secrets
+type CredentialSource interface {
+	Credentials() ([]Credential, error)
+}
+
+// CredentialSourceFunc is an adapter to use a function as a
+// CredentialSource
+type CredentialSourceFunc func() ([]Credential, error)
+
+// Credentials calls f()
+func (f CredentialSourceFunc) Credentials() ([]Credential, error) {
+	return f()
+}
+
+// EnvCredentials reads the credentials from the environment
+// variable with the given name. The variable holds a comma
+// separated list of apiKey:apiSecret pairs
+type EnvCredentials string
+
+// Credentials parses the environment variable
+func (e EnvCredentials) Credentials() ([]Credential, error) {
+	value := os.Getenv(string(e))
+	if len(value) == 0 {
+		return nil, fmt.Errorf("%s must be set", string(e))
+	}
+
+	var credentials []Credential
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("%s has an invalid apiKey:apiSecret pair",
+				string(e))
+		}
+		apiKey, err := strconv.Atoi(kv[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s has an invalid API key: %s",
+				string(e), err)
+		}
+		credentials = append(credentials, Credential{
+			APIKey:    apiKey,
+			APISecret: kv[1],
+		})
+	}
+	return credentials, nil
+}
+
+// FileCredentials reads the credentials from the JSON file at
+// the given path. The file holds a list of objects with the
+// fields apiKey and apiSecret
+type FileCredentials string
+
+// Credentials reads and parses the file
+func (f FileCredentials) Credentials() ([]Credential, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []Credential
+	if err = json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("%s could not be parsed: %s", string(f), err)
+	}
+	return credentials, nil
+}
+
+// Registry holds an OpenTok object for each of the projects
+// provided by a CredentialSource. It is safe for concurrent use
+type Registry struct {
+	source  CredentialSource
+	factory func(apiKey int, apiSecret string) *OpenTok
+
+	mu       sync.RWMutex
+	projects map[int]*OpenTok
+
+	// sourced holds the secrets last read from the source and
+	// rotated the secrets set with Rotate, which are kept until
+	// the source returns a different secret
+	sourced map[int]string
+	rotated map[int]string
+}
+
+// NewRegistry creates a Registry and loads the credentials
+// from source
+func NewRegistry(source CredentialSource) (*Registry, error) {
+	return NewRegistryWithFactory(source, New)
+}
+
+// NewRegistryWithFactory creates a Registry that uses factory
+// to create the OpenTok objects, e.g. NewWithAppEngine or a
+// function that sets up observers for every project
+func NewRegistryWithFactory(source CredentialSource,
+	factory func(apiKey int, apiSecret string) *OpenTok) (*Registry, error) {
+
+	r := &Registry{
+		source:   source,
+		factory:  factory,
+		projects: make(map[int]*OpenTok),
+		sourced:  make(map[int]string),
+		rotated:  make(map[int]string),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the credentials from the source again. Projects
+// whose secret changed get a new OpenTok object, projects that
+// are no longer provided are removed and the rest are kept.
+// A secret set with Rotate is kept until the source returns a
+// different secret for the project. If the source fails the
+// current projects are left untouched
+func (r *Registry) Reload() error {
+	credentials, err := r.source.Credentials()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	projects := make(map[int]*OpenTok, len(credentials))
+	sourced := make(map[int]string, len(credentials))
+	rotated := make(map[int]string)
+	for _, c := range credentials {
+		if _, ok := projects[c.APIKey]; ok {
+			return fmt.Errorf("API key %d is duplicated", c.APIKey)
+		}
+		sourced[c.APIKey] = c.APISecret
+
+		secret := c.APISecret
+		if s, ok := r.rotated[c.APIKey]; ok && c.APISecret == r.sourced[c.APIKey] {
+			// the source has not caught up with the rotation yet
+			secret = s
+			rotated[c.APIKey] = s
+		}
+		if ot, ok := r.projects[c.APIKey]; ok && ot.APISecret == secret {
+			projects[c.APIKey] = ot
+			continue
+		}
+		projects[c.APIKey] = r.factory(c.APIKey, secret)
+	}
+	r.projects = projects
+	r.sourced = sourced
+	r.rotated = rotated
+	return nil
+}
+
+// Rotate replaces the secret of a single project. OpenTok
+// objects that were already handed out keep the old secret.
+// Reload keeps the new secret until the source returns a
+// different one, so the source can be updated later
+func (r *Registry) Rotate(apiKey int, apiSecret string) error {
+	if len(apiSecret) == 0 {
+		return fmt.Errorf("apiSecret should not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.projects[apiKey]; !ok {
+		return fmt.Errorf("Unknown API key: %d", apiKey)
+	}
+	r.projects[apiKey] = r.factory(apiKey, apiSecret)
+	r.rotated[apiKey] = apiSecret
+	return nil
+}
+
+// Project returns the OpenTok object for apiKey
+func (r *Registry) Project(apiKey int) (*OpenTok, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ot, ok := r.projects[apiKey]
+	if !ok {
+		return nil, fmt.Errorf("Unknown API key: %d", apiKey)
+	}
+	return ot, nil
+}
+
+// ForSession returns the OpenTok object of the project the
+// session was created for
+func (r *Registry) ForSession(sessionID string) (*OpenTok, error) {
+	apiKey, err := SessionAPIKey(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Project(apiKey)
+}
+
+// ForToken returns the OpenTok object of the project the
+// token was generated for
+func (r *Registry) ForToken(token string) (*OpenTok, error) {
+	info, err := DecodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return r.Project(info.APIKey)
+}
+
+// APIKeys returns the API keys of all the projects in the
+// registry in ascending order
+func (r *Registry) APIKeys() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	apiKeys := make([]int, 0, len(r.projects))
+	for apiKey := range r.projects {
+		apiKeys = append(apiKeys, apiKey)
+	}
+	sort.Ints(apiKeys)
+	return apiKeys
+}
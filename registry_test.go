@@ -0,0 +1,167 @@
+package opentok
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry(CredentialSourceFunc(func() ([]Credential, error) {
+		return []Credential{
+			{APIKey: 100, APISecret: "secret100"},
+			{APIKey: 200, APISecret: "secret200"},
+		}, nil
+	}))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	if keys := r.APIKeys(); !reflect.DeepEqual(keys, []int{100, 200}) {
+		t.Fatalf("Unexpected API keys: %v", keys)
+	}
+
+	ot, err := r.ForSession(helpers.Session().ID(200))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if ot.APIKey != 200 || ot.APISecret != "secret200" {
+		t.Fatalf("Unexpected project: %d", ot.APIKey)
+	}
+
+	token, _ := ot.Token(sessionID, nil)
+	fromToken, err := r.ForToken(token.String())
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if fromToken != ot {
+		t.Fatalf("Token should resolve to the same project")
+	}
+
+	if _, err := r.Project(300); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	credentials := []Credential{
+		{APIKey: 100, APISecret: "secret100"},
+		{APIKey: 200, APISecret: "secret200"},
+	}
+	r, err := NewRegistry(CredentialSourceFunc(func() ([]Credential, error) {
+		return credentials, nil
+	}))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	unchanged, _ := r.Project(100)
+	rotated, _ := r.Project(200)
+
+	credentials = []Credential{
+		{APIKey: 100, APISecret: "secret100"},
+		{APIKey: 200, APISecret: "rotated200"},
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	if ot, _ := r.Project(100); ot != unchanged {
+		t.Fatalf("Project with the same secret should be kept")
+	}
+	ot, _ := r.Project(200)
+	if ot == rotated || ot.APISecret != "rotated200" {
+		t.Fatalf("Project secret should have been rotated")
+	}
+	if rotated.APISecret != "secret200" {
+		t.Fatalf("Objects handed out should keep the old secret")
+	}
+
+	credentials = []Credential{{APIKey: 100, APISecret: "secret100"}}
+	r.Reload()
+	if _, err := r.Project(200); err == nil {
+		t.Fatalf("Removed project should not be found")
+	}
+}
+
+func TestRegistryRotate(t *testing.T) {
+	r, _ := NewRegistry(CredentialSourceFunc(func() ([]Credential, error) {
+		return []Credential{{APIKey: 100, APISecret: "secret100"}}, nil
+	}))
+
+	if err := r.Rotate(100, "rotated"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if ot, _ := r.Project(100); ot.APISecret != "rotated" {
+		t.Fatalf("Unexpected secret: %s", ot.APISecret)
+	}
+	if err := r.Rotate(300, "secret"); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestRegistryRotateReload(t *testing.T) {
+	secret := "old"
+	r, _ := NewRegistry(CredentialSourceFunc(func() ([]Credential, error) {
+		return []Credential{{APIKey: 100, APISecret: secret}}, nil
+	}))
+
+	r.Rotate(100, "new")
+	r.Reload()
+	if ot, _ := r.Project(100); ot.APISecret != "new" {
+		t.Fatalf("The rotated secret should survive a reload: %s", ot.APISecret)
+	}
+
+	// once the source changes it is authoritative again
+	secret = "newer"
+	r.Reload()
+	if ot, _ := r.Project(100); ot.APISecret != "newer" {
+		t.Fatalf("Unexpected secret: %s", ot.APISecret)
+	}
+	secret = "old"
+	r.Reload()
+	if ot, _ := r.Project(100); ot.APISecret != "old" {
+		t.Fatalf("Unexpected secret: %s", ot.APISecret)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	os.Setenv("OPENTOK_TEST_CREDENTIALS", "100:secret100, 200:secret200")
+	defer os.Unsetenv("OPENTOK_TEST_CREDENTIALS")
+
+	credentials, err := EnvCredentials("OPENTOK_TEST_CREDENTIALS").Credentials()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	expected := []Credential{
+		{APIKey: 100, APISecret: "secret100"},
+		{APIKey: 200, APISecret: "secret200"},
+	}
+	if !reflect.DeepEqual(credentials, expected) {
+		t.Fatalf("Unexpected credentials: %v", credentials)
+	}
+
+	os.Setenv("OPENTOK_TEST_CREDENTIALS", "100")
+	if _, err := EnvCredentials("OPENTOK_TEST_CREDENTIALS").Credentials(); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "opentok")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.json")
+	ioutil.WriteFile(path,
+		[]byte(`[{"apiKey": 100, "apiSecret": "secret100"}]`), 0600)
+
+	credentials, err := FileCredentials(path).Credentials()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if len(credentials) != 1 || credentials[0].APISecret != "secret100" {
+		t.Fatalf("Unexpected credentials: %v", credentials)
+	}
+}
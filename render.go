@@ -0,0 +1,79 @@
+package opentok
+
+// Render is an Experience Composer: a web page rendered by the
+// OpenTok platform and published as a stream into a session, so
+// it shows up in composed archives and broadcasts
+type Render struct {
+	// ID of the render. It's used to stop and retrieve renders
+	ID string `json:"id"`
+
+	// SessionID to which the render publishes
+	SessionID string `json:"sessionId"`
+
+	// APIKey to which the render belongs
+	APIKey int `json:"projectId"`
+
+	// Unix timestamps in milliseconds of the creation and the
+	// last update of the render
+	CreatedAt int64 `json:"createdAt"`
+	UpdatedAt int64 `json:"updatedAt"`
+
+	// URL of the page being rendered
+	URL string `json:"url"`
+
+	// Resolution of the stream, e.g. 1280x720
+	Resolution string `json:"resolution"`
+
+	// Status of the render. The possibilities are:
+	// - `starting`: the page is being loaded
+	// - `started`: the page is being published to the session
+	// - `stopped`: the render has been stopped
+	// - `failed`: the render could not be started
+	Status string `json:"status"`
+
+	// StreamID is the id of the stream published by the render
+	StreamID string `json:"streamId"`
+
+	// Reason explains why the render was stopped or failed
+	Reason string `json:"reason"`
+}
+
+// RenderProps holds the settings used to start a render
+type RenderProps struct {
+	// URL of the page to render
+	URL string `json:"url"`
+
+	SessionID string `json:"sessionId"`
+
+	// Token used by the render to join the session. If it is
+	// empty a publisher token is generated
+	Token string `json:"token"`
+
+	// MaxDuration is the maximum time in seconds that the page is
+	// rendered. The platform default is 2 hours
+	MaxDuration int `json:"maxDuration,omitempty"`
+
+	// Resolution of the stream: 640x480, 1280x720, 1920x1080 or
+	// their portrait versions. The platform default is 1280x720
+	Resolution string `json:"resolution,omitempty"`
+
+	// Properties of the stream published by the render
+	Properties *RenderProperties `json:"properties,omitempty"`
+
+	// StatusCallbackURL receives the status changes of the render
+	StatusCallbackURL string `json:"statusCallbackUrl,omitempty"`
+}
+
+// RenderProperties are the properties of the stream published
+// by a render
+type RenderProperties struct {
+	// Name of the stream
+	Name string `json:"name,omitempty"`
+}
+
+// RenderList holds the list of renders retrieved from the
+// opentok service
+type RenderList struct {
+	Count   int      `json:"count"`
+	Renders []Render `json:"items"`
+}
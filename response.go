@@ -0,0 +1,77 @@
+package opentok
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxErrorBodySize is the largest part of an error response
+	// kept in the error message
+	maxErrorBodySize = 4 << 10
+
+	// maxDrainSize is how much of an unread body is discarded so
+	// the connection can be reused. Larger bodies are just closed
+	maxDrainSize = 64 << 10
+)
+
+// decodeBody decodes the body of res as XML or JSON depending on
+// its content type. Without a content type, bodies that start
+// with < are decoded as XML
+func decodeBody(res *http.Response, v interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch {
+	case strings.HasSuffix(mediaType, "xml"):
+		return xml.NewDecoder(res.Body).Decode(v)
+	case strings.HasSuffix(mediaType, "json"):
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+
+	body := bufio.NewReader(res.Body)
+	for {
+		b, err := body.Peek(1)
+		if err != nil {
+			return fmt.Errorf("empty response body: %s", err)
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			body.ReadByte()
+		case '<':
+			return xml.NewDecoder(body).Decode(v)
+		default:
+			return json.NewDecoder(body).Decode(v)
+		}
+	}
+}
+
+// closeBody discards what is left of the body of res and closes it
+func closeBody(res *http.Response) {
+	if res.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxDrainSize))
+	res.Body.Close()
+}
+
+// errFromStatusCode builds the APIError of a response with an
+// error status code. Up to maxErrorBodySize bytes of the body are
+// kept in the message
+func errFromStatusCode(res *http.Response, operation string) error {
+	var message string
+	if res.Body != nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		message = strings.TrimSpace(string(body))
+	}
+	return &APIError{
+		Operation:  operation,
+		StatusCode: res.StatusCode,
+		Message:    message,
+	}
+}
@@ -0,0 +1,132 @@
+package opentok
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clientFunc is an adapter to use a function as an httpClient
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// trackedBody records whether it has been read to the end and
+// closed
+type trackedBody struct {
+	io.Reader
+	eof    bool
+	closed bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func respondWith(statusCode int, contentType, body string) (httpClient, *trackedBody) {
+	tracked := &trackedBody{Reader: strings.NewReader(body)}
+	return clientFunc(func(req *http.Request) (*http.Response, error) {
+		res := &http.Response{
+			StatusCode:    statusCode,
+			Header:        make(http.Header),
+			Body:          tracked,
+			ContentLength: -1,
+		}
+		if len(contentType) > 0 {
+			res.Header.Set("Content-Type", contentType)
+		}
+		return res, nil
+	}), tracked
+}
+
+func TestResponseBodyClosed(t *testing.T) {
+	// the trailing data is left unread by the decoder
+	client, body := respondWith(200, "application/json",
+		`{"id":"archiveId"}`+strings.Repeat(" ", 100))
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if _, err := ot.ArchiveGet(archiveID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if !body.eof || !body.closed {
+		t.Fatalf("Expected the body to be drained and closed")
+	}
+
+	client, body = respondWith(200, "", "")
+	ot = newOpenTokWithClient(apiKey, apiSecret, client)
+	if err := ot.ArchiveStop(archiveID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if !body.closed {
+		t.Fatalf("Expected the body to be closed")
+	}
+}
+
+func TestResponseErrorBody(t *testing.T) {
+	// chunked responses have an unknown content length
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"archive is not started"}`))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("x", 2*maxErrorBodySize)))
+	}))
+	defer server.Close()
+
+	ot := newOpenTokWithURL(apiKey, apiSecret, server.URL)
+	err := ot.ArchiveStop(archiveID)
+	if err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if !strings.Contains(err.Error(), "statusCode: 409") ||
+		!strings.Contains(err.Error(), "archive is not started") {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(err.Error()) > maxErrorBodySize+100 {
+		t.Fatalf("Expected the error body to be capped: %d bytes", len(err.Error()))
+	}
+
+	client, body := respondWith(500, "", "internal error")
+	ot = newOpenTokWithClient(apiKey, apiSecret, client)
+	if err = ot.ArchiveStop(archiveID); err == nil ||
+		!strings.Contains(err.Error(), "internal error") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !body.closed {
+		t.Fatalf("Expected the error body to be closed")
+	}
+}
+
+func TestResponseContentType(t *testing.T) {
+	xmlBody := `<sessions><Session><session_id>xmlSession</session_id></Session></sessions>`
+	for _, contentType := range []string{"application/xml; charset=utf-8", ""} {
+		client, _ := respondWith(200, contentType, "\n"+xmlBody)
+		ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+		session, err := ot.Session(nil)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %s", err)
+		}
+		if session.ID != "xmlSession" {
+			t.Fatalf("Unexpected session: %s", session.ID)
+		}
+	}
+
+	client, _ := respondWith(200, "application/json; charset=utf-8", `{"id":"archiveId"}`)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+	if archive, err := ot.ArchiveGet(archiveID); err != nil || archive.ID != "archiveId" {
+		t.Fatalf("Unexpected archive: %v %v", archive, err)
+	}
+}
@@ -0,0 +1,173 @@
+package opentok
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// RetentionPolicy selects the archives that must be deleted and
+// deletes them. An archive is selected when it matches all the
+// criteria that are set
+type RetentionPolicy struct {
+	// MaxAge selects the archives created more than MaxAge ago
+	MaxAge time.Duration
+
+	// Statuses selects the archives in any of these statuses
+	Statuses []string
+
+	// NamePattern selects the archives whose name matches
+	NamePattern *regexp.Regexp
+
+	// SessionIDs selects the archives of any of these sessions
+	SessionIDs []string
+
+	// DryRun reports the archives that would be deleted without
+	// deleting them
+	DryRun bool
+
+	// Concurrency is the maximum number of archives deleted at
+	// the same time, 4 if it is 0
+	Concurrency int
+
+	// PageSize is the number of archives retrieved with every
+	// call to ArchiveList, 100 if it is 0
+	PageSize int
+
+	// Clock tells the current time to compute the age of the
+	// archives. The system clock is used if it is nil
+	Clock Clock
+}
+
+// RetentionResult is the outcome for one selected archive
+type RetentionResult struct {
+	Archive Archive
+	Deleted bool
+	Err     error
+}
+
+// RetentionReport describes what a RetentionPolicy did, or would
+// do in dry-run mode
+type RetentionReport struct {
+	DryRun  bool
+	Scanned int
+
+	// Results has an entry for every selected archive
+	Results []RetentionResult
+}
+
+// Deleted returns the number of archives that were deleted
+func (r *RetentionReport) Deleted() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of the archives that could not
+// be deleted
+func (r *RetentionReport) Failed() []RetentionResult {
+	var failed []RetentionResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Matches tells whether the archive is selected by the policy
+func (p *RetentionPolicy) Matches(a *Archive, now time.Time) bool {
+	if p.MaxAge > 0 {
+		created := time.Unix(0, a.CreatedAt*int64(time.Millisecond))
+		if now.Sub(created) <= p.MaxAge {
+			return false
+		}
+	}
+	if len(p.Statuses) > 0 && !containsString(p.Statuses, a.Status) {
+		return false
+	}
+	if p.NamePattern != nil && !p.NamePattern.MatchString(a.Name) {
+		return false
+	}
+	if len(p.SessionIDs) > 0 && !containsString(p.SessionIDs, a.SessionID) {
+		return false
+	}
+	return true
+}
+
+// Run lists all the archives of ot, selects the ones matching
+// the policy and deletes them. The archives are listed before
+// any of them is deleted, so deletions do not shift the pages.
+// If ctx is cancelled the archives not deleted yet are reported
+// with the context error
+func (p *RetentionPolicy) Run(ctx context.Context, ot *OpenTok) (*RetentionReport, error) {
+	if p.MaxAge == 0 && len(p.Statuses) == 0 && p.NamePattern == nil &&
+		len(p.SessionIDs) == 0 {
+		return nil, fmt.Errorf("RetentionPolicy must set at least one criteria")
+	}
+
+	clock := p.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	pageSize := p.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	report := &RetentionReport{DryRun: p.DryRun}
+	now := clock.Now()
+
+	for offset := 0; ; offset += pageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		list, err := ot.ArchiveList(pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Scanned += len(list.Archives)
+		for _, a := range list.Archives {
+			if p.Matches(&a, now) {
+				report.Results = append(report.Results, RetentionResult{Archive: a})
+			}
+		}
+
+		if len(list.Archives) < pageSize || offset+len(list.Archives) >= list.Count {
+			break
+		}
+	}
+
+	if p.DryRun {
+		return report, nil
+	}
+
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	ids := make([]string, len(report.Results))
+	for i, res := range report.Results {
+		ids[i] = res.Archive.ID
+	}
+	for i, res := range ot.ArchiveDeleteMany(ctx, ids, concurrency) {
+		report.Results[i].Err = res.Err
+		report.Results[i].Deleted = res.Err == nil
+	}
+	return report, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
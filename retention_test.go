@@ -0,0 +1,128 @@
+package opentok
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+// the archives returned by the helpers were created on
+// 2013-11-12 at 02:02:10 UTC
+var archiveCreatedAt = time.Unix(1384221730, 0)
+
+func newRetentionClient() *helpers.Client {
+	archive := func(id, name, status string) *helpers.ArchiveParams {
+		params := helpers.Archive().DefaultParams()
+		params.ID = id
+		params.Name = name
+		params.Status = status
+		return params
+	}
+
+	first := helpers.Archive().RequestList(apiKey, 2, 0).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	second := helpers.Archive().RequestList(apiKey, 2, 2).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	deleteClass1 := helpers.Archive().RequestDelete(apiKey, "class1").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	deleteClass3 := helpers.Archive().RequestDelete(apiKey, "class3").
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+
+	return helpers.NewClient().
+		Add(first, helpers.Archive().ValidResponseWithArchives(3,
+			archive("class1", "class-1", "available"),
+			archive("other", "other", "available"))).
+		Add(second, helpers.Archive().ValidResponseWithArchives(3,
+			archive("class3", "class-3", "available"))).
+		Add(deleteClass1, helpers.Archive().ValidResponseEmpty()).
+		Add(deleteClass3, helpers.NewResponse(409))
+}
+
+func TestRetentionPolicyDryRun(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, newRetentionClient())
+	policy := &RetentionPolicy{
+		MaxAge:      30 * 24 * time.Hour,
+		NamePattern: regexp.MustCompile("^class-"),
+		DryRun:      true,
+		PageSize:    2,
+		Clock: ClockFunc(func() time.Time {
+			return archiveCreatedAt.Add(31 * 24 * time.Hour)
+		}),
+	}
+
+	report, err := policy.Run(context.Background(), ot)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if report.Scanned != 3 {
+		t.Fatalf("Expected 3 archives to be scanned: %d", report.Scanned)
+	}
+	if len(report.Results) != 2 || report.Deleted() != 0 {
+		t.Fatalf("Expected 2 archives to be selected and none deleted: %v",
+			report.Results)
+	}
+}
+
+func TestRetentionPolicyDelete(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, newRetentionClient())
+	policy := &RetentionPolicy{
+		NamePattern: regexp.MustCompile("^class-"),
+		Statuses:    []string{"available"},
+		PageSize:    2,
+		Concurrency: 2,
+	}
+
+	report, err := policy.Run(context.Background(), ot)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if report.Deleted() != 1 {
+		t.Fatalf("Expected 1 archive to be deleted: %d", report.Deleted())
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Archive.ID != "class3" {
+		t.Fatalf("Expected class3 to fail: %v", failed)
+	}
+}
+
+func TestRetentionPolicyMatches(t *testing.T) {
+	archive := &Archive{
+		CreatedAt: archiveCreatedAt.UnixNano() / int64(time.Millisecond),
+		Name:      "class-1",
+		SessionID: sessionID,
+		Status:    "available",
+	}
+	now := archiveCreatedAt.Add(10 * 24 * time.Hour)
+
+	policies := map[*RetentionPolicy]bool{
+		{MaxAge: 5 * 24 * time.Hour}:                 true,
+		{MaxAge: 15 * 24 * time.Hour}:                false,
+		{Statuses: []string{"uploaded"}}:             false,
+		{SessionIDs: []string{sessionID}}:            true,
+		{NamePattern: regexp.MustCompile("^other")}:  false,
+		{Statuses: []string{"available", "expired"}}: true,
+	}
+	for policy, expected := range policies {
+		if policy.Matches(archive, now) != expected {
+			t.Fatalf("Unexpected match for %+v: expected %t", policy, expected)
+		}
+	}
+}
+
+func TestRetentionPolicyFails(t *testing.T) {
+	ot := newOpenTokWithClient(apiKey, apiSecret, helpers.NewClient())
+
+	if _, err := (&RetentionPolicy{}).Run(context.Background(), ot); err == nil {
+		t.Fatalf("A policy without criteria should fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy := &RetentionPolicy{Statuses: []string{"available"}}
+	if _, err := policy.Run(ctx, ot); err == nil {
+		t.Fatalf("A cancelled context should fail")
+	}
+}
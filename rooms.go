@@ -0,0 +1,283 @@
+package opentok
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SessionCreator creates OpenTok sessions. It is implemented
+// by OpenTok
+type SessionCreator interface {
+	Session(props *SessionProps) (*Session, error)
+}
+
+// RoomStore keeps the session id of each room. Implementations
+// must be safe for concurrent use
+type RoomStore interface {
+	// Get returns the session id of room. ok is false if the
+	// room does not have a session yet
+	Get(room string) (sessionID string, ok bool, err error)
+	Put(room, sessionID string) error
+	Delete(room string) error
+}
+
+// MemoryRoomStore is a RoomStore that keeps the rooms in memory.
+// The rooms are lost when the process exits
+type MemoryRoomStore struct {
+	mu    sync.RWMutex
+	rooms map[string]string
+}
+
+// NewMemoryRoomStore creates an empty MemoryRoomStore
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{rooms: make(map[string]string)}
+}
+
+// Get returns the session id of room
+func (s *MemoryRoomStore) Get(room string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessionID, ok := s.rooms[room]
+	return sessionID, ok, nil
+}
+
+// Put sets the session id of room
+func (s *MemoryRoomStore) Put(room, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rooms[room] = sessionID
+	return nil
+}
+
+// Delete removes room
+func (s *MemoryRoomStore) Delete(room string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.rooms, room)
+	return nil
+}
+
+// FileRoomStore is a RoomStore that keeps the rooms in memory
+// and saves them to a JSON file every time they change. The file
+// must not be shared by several processes
+type FileRoomStore struct {
+	path  string
+	mu    sync.RWMutex
+	rooms map[string]string
+}
+
+// NewFileRoomStore creates a FileRoomStore that loads the rooms
+// from path. The file is created on the first change if it does
+// not exist
+func NewFileRoomStore(path string) (*FileRoomStore, error) {
+	s := &FileRoomStore{
+		path:  path,
+		rooms: make(map[string]string),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.rooms); err != nil {
+		return nil, fmt.Errorf("%s could not be parsed: %s", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the session id of room
+func (s *FileRoomStore) Get(room string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessionID, ok := s.rooms[room]
+	return sessionID, ok, nil
+}
+
+// Put sets the session id of room and saves the file
+func (s *FileRoomStore) Put(room, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.rooms[room]
+	s.rooms[room] = sessionID
+	if err := s.save(); err != nil {
+		if existed {
+			s.rooms[room] = previous
+		} else {
+			delete(s.rooms, room)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes room and saves the file
+func (s *FileRoomStore) Delete(room string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionID, ok := s.rooms[room]
+	if !ok {
+		return nil
+	}
+	delete(s.rooms, room)
+	if err := s.save(); err != nil {
+		s.rooms[room] = sessionID
+		return err
+	}
+	return nil
+}
+
+func (s *FileRoomStore) save() error {
+	data, err := json.MarshalIndent(s.rooms, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file and renames
+// it to path, so the file is never left half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// RoomManager maps application room names to OpenTok sessions.
+// The session of a room is created the first time the room is
+// used. Concurrent calls for the same room share a single
+// session creation
+type RoomManager struct {
+	creator SessionCreator
+	store   RoomStore
+
+	mu           sync.Mutex
+	defaultProps SessionProps
+	props        map[string]SessionProps
+	calls        map[string]*roomCall
+}
+
+// roomCall is a session creation in progress
+type roomCall struct {
+	done    chan struct{}
+	session *Session
+	err     error
+}
+
+// NewRoomManager creates a RoomManager that creates the sessions
+// with creator, usually an OpenTok object, and keeps the rooms
+// in store
+func NewRoomManager(creator SessionCreator, store RoomStore) *RoomManager {
+	return &RoomManager{
+		creator: creator,
+		store:   store,
+		props:   make(map[string]SessionProps),
+		calls:   make(map[string]*roomCall),
+	}
+}
+
+// SetDefaultProps sets the properties used to create the session
+// of the rooms that do not have their own properties
+func (m *RoomManager) SetDefaultProps(props SessionProps) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.defaultProps = props
+}
+
+// SetRoomProps sets the properties used to create the session of
+// room. It has no effect if the session already exists
+func (m *RoomManager) SetRoomProps(room string, props SessionProps) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.props[room] = props
+}
+
+// Session returns the session of room, creating it if the room
+// does not have a session yet
+func (m *RoomManager) Session(room string) (*Session, error) {
+	if len(room) == 0 {
+		return nil, fmt.Errorf("room should not be empty")
+	}
+
+	if sessionID, ok, err := m.store.Get(room); err != nil {
+		return nil, err
+	} else if ok {
+		return &Session{ID: sessionID}, nil
+	}
+
+	m.mu.Lock()
+	if call, ok := m.calls[room]; ok {
+		m.mu.Unlock()
+		<-call.done
+		return call.session, call.err
+	}
+	call := &roomCall{done: make(chan struct{})}
+	m.calls[room] = call
+	props, ok := m.props[room]
+	if !ok {
+		props = m.defaultProps
+	}
+	m.mu.Unlock()
+
+	call.session, call.err = m.create(room, &props)
+
+	m.mu.Lock()
+	delete(m.calls, room)
+	m.mu.Unlock()
+	close(call.done)
+
+	return call.session, call.err
+}
+
+func (m *RoomManager) create(room string, props *SessionProps) (*Session, error) {
+	// another call may have stored the session between the
+	// first lookup and the registration of this call
+	if sessionID, ok, err := m.store.Get(room); err != nil {
+		return nil, err
+	} else if ok {
+		return &Session{ID: sessionID}, nil
+	}
+
+	session, err := m.creator.Session(props)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.store.Put(room, session.ID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Remove forgets the session of room. The next call to Session
+// for the room creates a new session
+func (m *RoomManager) Remove(room string) error {
+	return m.store.Delete(room)
+}
@@ -0,0 +1,138 @@
+package opentok
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingCreator is a SessionCreator that records the
+// properties of every session it creates
+type countingCreator struct {
+	mu    sync.Mutex
+	props []SessionProps
+	delay time.Duration
+}
+
+func (c *countingCreator) Session(props *SessionProps) (*Session, error) {
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.props = append(c.props, *props)
+	return &Session{ID: fmt.Sprintf("session%d", len(c.props))}, nil
+}
+
+func (c *countingCreator) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.props)
+}
+
+func TestRoomManagerGetOrCreate(t *testing.T) {
+	creator := &countingCreator{}
+	m := NewRoomManager(creator, NewMemoryRoomStore())
+
+	first, err := m.Session("lobby")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	second, _ := m.Session("lobby")
+	other, _ := m.Session("other")
+
+	if first.ID != second.ID {
+		t.Fatalf("The same room should have the same session: %s, %s",
+			first.ID, second.ID)
+	}
+	if first.ID == other.ID {
+		t.Fatalf("Different rooms should have different sessions")
+	}
+	if creator.count() != 2 {
+		t.Fatalf("Expected two sessions to be created: %d", creator.count())
+	}
+
+	if err := m.Remove("lobby"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if third, _ := m.Session("lobby"); third.ID == first.ID {
+		t.Fatalf("A removed room should get a new session")
+	}
+}
+
+func TestRoomManagerConcurrentJoins(t *testing.T) {
+	creator := &countingCreator{delay: 20 * time.Millisecond}
+	m := NewRoomManager(creator, NewMemoryRoomStore())
+
+	var wg sync.WaitGroup
+	ids := make([]string, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := m.Session("lobby")
+			if err != nil {
+				t.Errorf("Expected err to be nil: %s", err)
+				return
+			}
+			ids[i] = s.ID
+		}(i)
+	}
+	wg.Wait()
+
+	if creator.count() != 1 {
+		t.Fatalf("Expected one session to be created: %d", creator.count())
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("All joins should get the same session: %v", ids)
+		}
+	}
+}
+
+func TestRoomManagerProps(t *testing.T) {
+	creator := &countingCreator{}
+	m := NewRoomManager(creator, NewMemoryRoomStore())
+	m.SetDefaultProps(SessionProps{ArchiveMode: Always})
+	m.SetRoomProps("small", SessionProps{MediaMode: Relayed})
+
+	m.Session("big")
+	m.Session("small")
+
+	if creator.props[0].ArchiveMode != Always {
+		t.Fatalf("Default props should be used: %v", creator.props[0])
+	}
+	if creator.props[1].MediaMode != Relayed {
+		t.Fatalf("Room props should be used: %v", creator.props[1])
+	}
+}
+
+func TestFileRoomStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "opentok")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+
+	store, err := NewFileRoomStore(path)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	store.Put("lobby", "session1")
+	store.Put("other", "session2")
+	store.Delete("other")
+
+	reloaded, err := NewFileRoomStore(path)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if sessionID, ok, _ := reloaded.Get("lobby"); !ok || sessionID != "session1" {
+		t.Fatalf("Unexpected session for lobby: %s", sessionID)
+	}
+	if _, ok, _ := reloaded.Get("other"); ok {
+		t.Fatalf("Deleted room should not be found")
+	}
+}
@@ -0,0 +1,477 @@
+package opentok
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleState is the state of an ArchiveSchedule
+type ScheduleState string
+
+const (
+	// SchedulePending the archive has not been started yet
+	SchedulePending ScheduleState = "pending"
+
+	// ScheduleRecording the archive has been started. It may be
+	// paused if nobody is publishing in the session
+	ScheduleRecording ScheduleState = "recording"
+
+	// ScheduleDone the archive has been stopped
+	ScheduleDone ScheduleState = "done"
+
+	// ScheduleFailed the archive could not be started before
+	// the end of its window
+	ScheduleFailed ScheduleState = "failed"
+)
+
+// ArchiveSchedule is an archive that ArchiveScheduler starts at
+// StartAt and stops after MaxDuration
+type ArchiveSchedule struct {
+	ID        string       `json:"id"`
+	SessionID string       `json:"sessionId"`
+	Props     ArchiveProps `json:"props"`
+	StartAt   time.Time    `json:"startAt"`
+
+	// MaxDuration is the maximum time the archive is recorded.
+	// If it is 0 the archive is recorded until it is cancelled
+	MaxDuration time.Duration `json:"maxDuration"`
+
+	State     ScheduleState `json:"state"`
+	ArchiveID string        `json:"archiveId,omitempty"`
+	StartedAt time.Time     `json:"startedAt,omitempty"`
+
+	// Error is the last error found starting or stopping the
+	// archive. The scheduler keeps retrying while it makes sense
+	Error string `json:"error,omitempty"`
+}
+
+// ScheduleStore keeps the schedules of an ArchiveScheduler so
+// they survive restarts. Implementations must be safe for
+// concurrent use
+type ScheduleStore interface {
+	Save(s *ArchiveSchedule) error
+	Delete(id string) error
+	List() ([]*ArchiveSchedule, error)
+}
+
+// MemoryScheduleStore is a ScheduleStore that keeps the
+// schedules in memory
+type MemoryScheduleStore struct {
+	mu        sync.RWMutex
+	schedules map[string]ArchiveSchedule
+}
+
+// NewMemoryScheduleStore creates an empty MemoryScheduleStore
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{schedules: make(map[string]ArchiveSchedule)}
+}
+
+// Save adds or replaces s
+func (m *MemoryScheduleStore) Save(s *ArchiveSchedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.schedules[s.ID] = *s
+	return nil
+}
+
+// Delete removes the schedule with the given id
+func (m *MemoryScheduleStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.schedules, id)
+	return nil
+}
+
+// List returns a copy of all the schedules
+func (m *MemoryScheduleStore) List() ([]*ArchiveSchedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]*ArchiveSchedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		s := s
+		list = append(list, &s)
+	}
+	return list, nil
+}
+
+// FileScheduleStore is a ScheduleStore that saves the schedules
+// to a JSON file every time they change. The file must not be
+// shared by several processes
+type FileScheduleStore struct {
+	path string
+	*MemoryScheduleStore
+}
+
+// NewFileScheduleStore creates a FileScheduleStore that loads
+// the schedules from path
+func NewFileScheduleStore(path string) (*FileScheduleStore, error) {
+	f := &FileScheduleStore{
+		path:                path,
+		MemoryScheduleStore: NewMemoryScheduleStore(),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &f.schedules); err != nil {
+		return nil, fmt.Errorf("%s could not be parsed: %s", path, err)
+	}
+	return f, nil
+}
+
+// Save adds or replaces s and saves the file
+func (f *FileScheduleStore) Save(s *ArchiveSchedule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, existed := f.schedules[s.ID]
+	f.schedules[s.ID] = *s
+	if err := f.save(); err != nil {
+		if existed {
+			f.schedules[s.ID] = previous
+		} else {
+			delete(f.schedules, s.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the schedule with the given id and saves the file
+func (f *FileScheduleStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.schedules[id]
+	if !ok {
+		return nil
+	}
+	delete(f.schedules, id)
+	if err := f.save(); err != nil {
+		f.schedules[id] = s
+		return err
+	}
+	return nil
+}
+
+func (f *FileScheduleStore) save() error {
+	data, err := json.MarshalIndent(f.schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+// ArchiveScheduler starts archives at a scheduled time and stops
+// them when they reach their maximum duration. The schedules are
+// kept in a ScheduleStore, so a new scheduler created with the
+// same store after a restart carries on where the old one left
+type ArchiveScheduler struct {
+	ot       *OpenTok
+	store    ScheduleStore
+	clock    Clock
+	interval time.Duration
+
+	// mu makes sure that a schedule is never handled by two
+	// checks at the same time
+	mu sync.Mutex
+
+	// unsaved holds the schedules whose changes could not be
+	// saved. They take precedence over the store until they are
+	unsaved map[string]*ArchiveSchedule
+
+	// runMu guards the background checks of Start and Stop
+	runMu   sync.Mutex
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewArchiveScheduler creates an ArchiveScheduler that manages
+// the archives with ot and keeps the schedules in store
+func NewArchiveScheduler(ot *OpenTok, store ScheduleStore) *ArchiveScheduler {
+	return &ArchiveScheduler{
+		ot:       ot,
+		store:    store,
+		clock:    systemClock{},
+		interval: time.Second,
+		unsaved:  make(map[string]*ArchiveSchedule),
+	}
+}
+
+// SetClock replaces the clock used to decide when the archives
+// are started and stopped
+func (s *ArchiveScheduler) SetClock(c Clock) {
+	s.clock = c
+}
+
+// SetInterval sets how often the schedules are checked. It
+// must be called before Start. Intervals that are not positive
+// are ignored
+func (s *ArchiveScheduler) SetInterval(d time.Duration) {
+	if d > 0 {
+		s.interval = d
+	}
+}
+
+// Schedule adds an archive of the session that starts at startAt
+// and is stopped after maxDuration
+func (s *ArchiveScheduler) Schedule(sessionID string, props *ArchiveProps,
+	startAt time.Time, maxDuration time.Duration) (*ArchiveSchedule, error) {
+
+	if len(sessionID) == 0 {
+		return nil, fmt.Errorf("Session has empty id")
+	}
+	if maxDuration < 0 {
+		return nil, fmt.Errorf("maxDuration must not be negative: %s", maxDuration)
+	}
+	if props == nil {
+		props = &ArchiveProps{
+			HasAudio: true,
+			HasVideo: true,
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	schedule := &ArchiveSchedule{
+		ID:          hex.EncodeToString(id),
+		SessionID:   sessionID,
+		Props:       *props,
+		StartAt:     startAt,
+		MaxDuration: maxDuration,
+		State:       SchedulePending,
+	}
+	schedule.Props.SessionID = sessionID
+	if err := s.store.Save(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Cancel removes a schedule. If the archive is being recorded
+// it is stopped first
+func (s *ArchiveScheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if schedule.ID != id {
+			continue
+		}
+		if schedule.State == ScheduleRecording {
+			if err := s.stopArchive(schedule); err != nil {
+				return err
+			}
+		}
+		if err := s.store.Delete(id); err != nil {
+			return err
+		}
+		delete(s.unsaved, id)
+		return nil
+	}
+	return fmt.Errorf("Unknown schedule: %s", id)
+}
+
+// Schedules returns all the schedules sorted by start time
+func (s *ArchiveScheduler) Schedules() ([]*ArchiveSchedule, error) {
+	s.mu.Lock()
+	schedules, err := s.list()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].StartAt.Before(schedules[j].StartAt)
+	})
+	return schedules, nil
+}
+
+// Start checks the schedules periodically in the background
+// until Stop is called. It does nothing if the checks are
+// already running
+func (s *ArchiveScheduler) Start() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	stop, done := s.stop, s.done
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.Check()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the background checks started by Start. The
+// archives being recorded are not stopped. It does nothing if
+// the checks are not running
+func (s *ArchiveScheduler) Stop() {
+	s.runMu.Lock()
+	if !s.running {
+		s.runMu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.runMu.Unlock()
+
+	<-done
+}
+
+// Check starts the archives whose time has come and stops the
+// ones that reached their maximum duration. It is called
+// periodically after Start, but it can also be called directly.
+// A schedule that cannot be saved is kept in memory and saved
+// again by the next check, and the other schedules are still
+// checked
+func (s *ArchiveScheduler) Check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	var messages []string
+	for _, schedule := range schedules {
+		_, changed := s.unsaved[schedule.ID]
+		switch schedule.State {
+		case SchedulePending:
+			changed = s.checkPending(schedule, now) || changed
+		case ScheduleRecording:
+			changed = s.checkRecording(schedule, now) || changed
+		}
+		if !changed {
+			continue
+		}
+		if err := s.store.Save(schedule); err != nil {
+			s.unsaved[schedule.ID] = schedule
+			messages = append(messages, fmt.Sprintf("%s: %s", schedule.ID, err))
+			continue
+		}
+		delete(s.unsaved, schedule.ID)
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("%d schedules could not be saved: %s", len(messages),
+			strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// list returns the schedules of the store with the changes that
+// could not be saved. s.mu must be held
+func (s *ArchiveScheduler) list() ([]*ArchiveSchedule, error) {
+	schedules, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	for i, schedule := range schedules {
+		if unsaved, ok := s.unsaved[schedule.ID]; ok {
+			schedules[i] = unsaved
+		}
+	}
+	return schedules, nil
+}
+
+func (s *ArchiveScheduler) checkPending(schedule *ArchiveSchedule, now time.Time) bool {
+	if now.Before(schedule.StartAt) {
+		return false
+	}
+
+	// there is no point in starting an archive that should
+	// have already been stopped
+	if schedule.MaxDuration > 0 && !now.Before(schedule.StartAt.Add(schedule.MaxDuration)) {
+		schedule.State = ScheduleFailed
+		if len(schedule.Error) == 0 {
+			schedule.Error = "the archive was not started before its end"
+		}
+		return true
+	}
+
+	props := schedule.Props
+	archive, err := s.ot.ArchiveStart(schedule.SessionID, &props)
+	if err != nil {
+		schedule.Error = err.Error()
+		return true
+	}
+
+	schedule.State = ScheduleRecording
+	schedule.ArchiveID = archive.ID
+	schedule.StartedAt = now
+	schedule.Error = ""
+	return true
+}
+
+func (s *ArchiveScheduler) checkRecording(schedule *ArchiveSchedule, now time.Time) bool {
+	if schedule.MaxDuration <= 0 ||
+		now.Before(schedule.StartedAt.Add(schedule.MaxDuration)) {
+		return false
+	}
+
+	if err := s.stopArchive(schedule); err != nil {
+		schedule.Error = err.Error()
+	}
+	return true
+}
+
+// stopArchive stops the archive of schedule. Archives that are
+// started or paused are stopped, archives that have already
+// been stopped by someone else are only marked as done
+func (s *ArchiveScheduler) stopArchive(schedule *ArchiveSchedule) error {
+	err := s.ot.ArchiveStop(schedule.ArchiveID)
+	if err != nil {
+		archive, getErr := s.ot.ArchiveGet(schedule.ArchiveID)
+		if getErr != nil {
+			return err
+		}
+		if archive.Status == "started" || archive.Status == "paused" {
+			return err
+		}
+	}
+
+	schedule.State = ScheduleDone
+	schedule.Error = ""
+	return nil
+}
@@ -0,0 +1,289 @@
+package opentok
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eauge/opentok-go-sdk/helpers"
+)
+
+// fakeClock is a Clock that only moves when the test says so
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newSchedulerClient() *helpers.Client {
+	start := helpers.Archive().RequestStart(apiKey, sessionID, nil).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	stop := helpers.Archive().RequestStop(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	return helpers.NewClient().
+		Add(start, helpers.Archive().ValidResponseWithArchive(
+			helpers.Archive().DefaultParams())).
+		Add(stop, helpers.Archive().ValidResponseEmpty())
+}
+
+func newTestScheduler(client *helpers.Client, store ScheduleStore,
+	clock *fakeClock) *ArchiveScheduler {
+
+	s := NewArchiveScheduler(newOpenTokWithClient(apiKey, apiSecret, client), store)
+	s.SetClock(clock)
+	return s
+}
+
+func scheduleState(t *testing.T, s *ArchiveScheduler, id string) *ArchiveSchedule {
+	schedules, err := s.Schedules()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	for _, schedule := range schedules {
+		if schedule.ID == id {
+			return schedule
+		}
+	}
+	t.Fatalf("Schedule %s not found", id)
+	return nil
+}
+
+func TestArchiveScheduler(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	s := newTestScheduler(newSchedulerClient(), NewMemoryScheduleStore(), clock)
+
+	schedule, err := s.Schedule(sessionID, nil, clock.now.Add(time.Minute),
+		30*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+
+	s.Check()
+	if state := scheduleState(t, s, schedule.ID).State; state != SchedulePending {
+		t.Fatalf("Unexpected state before start time: %s", state)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	s.Check()
+	current := scheduleState(t, s, schedule.ID)
+	if current.State != ScheduleRecording || current.ArchiveID != archiveID {
+		t.Fatalf("Archive should be recording: %s %s", current.State, current.Error)
+	}
+
+	clock.now = clock.now.Add(20 * time.Minute)
+	s.Check()
+	if state := scheduleState(t, s, schedule.ID).State; state != ScheduleRecording {
+		t.Fatalf("Archive should still be recording: %s", state)
+	}
+
+	clock.now = clock.now.Add(10 * time.Minute)
+	s.Check()
+	if state := scheduleState(t, s, schedule.ID).State; state != ScheduleDone {
+		t.Fatalf("Archive should have been stopped: %s", state)
+	}
+}
+
+func TestArchiveSchedulerPaused(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	store := NewMemoryScheduleStore()
+	store.Save(&ArchiveSchedule{
+		ID:          "paused",
+		SessionID:   sessionID,
+		StartAt:     clock.now,
+		MaxDuration: time.Minute,
+		State:       ScheduleRecording,
+		ArchiveID:   archiveID,
+		StartedAt:   clock.now,
+	})
+
+	// the stop request fails and the archive is still paused
+	get := helpers.Archive().RequestGet(apiKey, archiveID).
+		AddHeader("X-TB-PARTNER-AUTH", partnerAuth)
+	params := helpers.Archive().DefaultParams()
+	params.Status = "paused"
+	client := helpers.NewClient().
+		Add(get, helpers.Archive().ValidResponseWithArchive(params))
+	client.SetDefaultResponse(helpers.NewResponse(409))
+	s := newTestScheduler(client, store, clock)
+
+	clock.now = clock.now.Add(time.Minute)
+	s.Check()
+	current := scheduleState(t, s, "paused")
+	if current.State != ScheduleRecording || len(current.Error) == 0 {
+		t.Fatalf("Paused archive should be retried: %s %s",
+			current.State, current.Error)
+	}
+
+	// the archive was stopped by someone else
+	params.Status = "stopped"
+	client = helpers.NewClient().
+		Add(get, helpers.Archive().ValidResponseWithArchive(params))
+	client.SetDefaultResponse(helpers.NewResponse(409))
+	s.ot = newOpenTokWithClient(apiKey, apiSecret, client)
+
+	s.Check()
+	if state := scheduleState(t, s, "paused").State; state != ScheduleDone {
+		t.Fatalf("Stopped archive should be done: %s", state)
+	}
+}
+
+func TestArchiveSchedulerMissed(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	s := newTestScheduler(newSchedulerClient(), NewMemoryScheduleStore(), clock)
+
+	schedule, _ := s.Schedule(sessionID, nil, clock.now, time.Minute)
+	clock.now = clock.now.Add(time.Hour)
+	s.Check()
+
+	if state := scheduleState(t, s, schedule.ID).State; state != ScheduleFailed {
+		t.Fatalf("Missed archive should fail: %s", state)
+	}
+}
+
+func TestArchiveSchedulerRestart(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "opentok")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedules.json")
+
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	store, _ := NewFileScheduleStore(path)
+	s := newTestScheduler(newSchedulerClient(), store, clock)
+	schedule, _ := s.Schedule(sessionID, nil, clock.now, time.Hour)
+	s.Check()
+
+	reloaded, err := NewFileScheduleStore(path)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	s = newTestScheduler(newSchedulerClient(), reloaded, clock)
+	current := scheduleState(t, s, schedule.ID)
+	if current.State != ScheduleRecording || current.ArchiveID != archiveID {
+		t.Fatalf("Schedule should survive the restart: %s", current.State)
+	}
+
+	if err := s.Cancel(schedule.ID); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if schedules, _ := s.Schedules(); len(schedules) != 0 {
+		t.Fatalf("Cancelled schedule should be removed")
+	}
+}
+
+func TestArchiveSchedulerStart(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	s := newTestScheduler(newSchedulerClient(), NewMemoryScheduleStore(), clock)
+	s.SetInterval(time.Millisecond)
+
+	schedule, _ := s.Schedule(sessionID, nil, clock.now, 0)
+	s.Start()
+	waitFor(t, func() bool {
+		schedules, _ := s.store.List()
+		return schedules[0].State == ScheduleRecording
+	})
+	s.Stop()
+
+	if current := scheduleState(t, s, schedule.ID); current.ArchiveID != archiveID {
+		t.Fatalf("Unexpected archive id: %s", current.ArchiveID)
+	}
+}
+
+func TestArchiveSchedulerStopTwice(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	s := newTestScheduler(newSchedulerClient(), NewMemoryScheduleStore(), clock)
+	s.SetInterval(time.Millisecond)
+
+	// neither Stop before Start nor a second Stop panic
+	s.Stop()
+	s.Start()
+	s.Start()
+	s.Stop()
+	s.Stop()
+}
+
+// failingStore is a ScheduleStore whose Save fails the given
+// number of times for a schedule
+type failingStore struct {
+	*MemoryScheduleStore
+	id       string
+	failures int
+}
+
+func (s *failingStore) Save(schedule *ArchiveSchedule) error {
+	if schedule.ID == s.id && s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("store unavailable")
+	}
+	return s.MemoryScheduleStore.Save(schedule)
+}
+
+func TestArchiveSchedulerSaveFails(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	store := &failingStore{MemoryScheduleStore: NewMemoryScheduleStore(), id: "a"}
+	store.Save(&ArchiveSchedule{
+		ID:          "a",
+		SessionID:   sessionID,
+		Props:       ArchiveProps{HasAudio: true, HasVideo: true},
+		StartAt:     clock.now,
+		MaxDuration: time.Minute,
+		State:       SchedulePending,
+	})
+	store.Save(&ArchiveSchedule{
+		ID:          "b",
+		SessionID:   sessionID,
+		StartAt:     clock.now.Add(-time.Hour),
+		MaxDuration: time.Minute,
+		State:       ScheduleRecording,
+		ArchiveID:   archiveID,
+		StartedAt:   clock.now.Add(-time.Hour),
+	})
+	store.failures = 1
+	s := newTestScheduler(newSchedulerClient(), store, clock)
+
+	// the started archive is kept even if it cannot be saved, and
+	// the other schedules are still checked
+	if err := s.Check(); err == nil {
+		t.Fatalf("Expected err not to be nil")
+	}
+	if current := scheduleState(t, s, "a"); current.State != ScheduleRecording ||
+		current.ArchiveID != archiveID {
+		t.Fatalf("Unexpected schedule: %+v", current)
+	}
+	if state := scheduleState(t, s, "b").State; state != ScheduleDone {
+		t.Fatalf("Unexpected state: %s", state)
+	}
+
+	// the next check saves it
+	if err := s.Check(); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	schedules, _ := store.List()
+	for _, schedule := range schedules {
+		if schedule.ID == "a" && schedule.State != ScheduleRecording {
+			t.Fatalf("Unexpected saved schedule: %+v", schedule)
+		}
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	s.Check()
+	if state := scheduleState(t, s, "a").State; state != ScheduleDone {
+		t.Fatalf("The archive should have been stopped: %s", state)
+	}
+}
+
+func TestArchiveSchedulerInterval(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1435484890, 0)}
+	s := newTestScheduler(newSchedulerClient(), NewMemoryScheduleStore(), clock)
+
+	s.SetInterval(0)
+	s.SetInterval(-time.Second)
+	if s.interval != time.Second {
+		t.Fatalf("Unexpected interval: %s", s.interval)
+	}
+	s.Start()
+	s.Stop()
+}
@@ -1,6 +1,12 @@
 package opentok
 
-import "encoding/xml"
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type xmlSessions struct {
 	XMLName  xml.Name     `xml:"sessions"`
@@ -52,7 +58,8 @@ type SessionProps struct {
 
 	// Location an IP address that enables the user to specifiy a preferred
 	// location to be considered when creating the session in
-	// the platform
+	// the platform. If it is empty, the region hint of the OpenTok
+	// object is used, see SetRegionHint
 	Location    string
 	MediaMode   MediaMode
 	ArchiveMode ArchiveMode
@@ -107,3 +114,103 @@ type TokenProps struct {
 type Session struct {
 	ID string
 }
+
+// TokenInfo holds the values a token was generated with. It
+// is obtained with DecodeToken
+type TokenInfo struct {
+	APIKey     int
+	Signature  string
+	SessionID  string
+	CreateTime int64
+	ExpireTime int64
+	Nonce      int64
+	Role       Role
+	Data       string
+}
+
+// DecodeToken extracts the values a token was generated with.
+// The signature is not verified, so the values must not be
+// trusted unless the token was generated by this process
+func DecodeToken(token string) (*TokenInfo, error) {
+	if !strings.HasPrefix(token, "T1==") {
+		return nil, fmt.Errorf("Token has an unknown format")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(token[4:])
+	if err != nil {
+		return nil, fmt.Errorf("Token could not be decoded: %s", err)
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Token has no signature")
+	}
+
+	var (
+		info   = &TokenInfo{}
+		header = parseTokenParams(parts[0])
+		key    = parts[1]
+	)
+
+	// connection_data is always the last parameter and is not
+	// encoded, so it may contain any character
+	if i := strings.Index(key, "&connection_data="); i >= 0 {
+		info.Data = key[i+len("&connection_data="):]
+		key = key[:i]
+	}
+	params := parseTokenParams(key)
+
+	if info.APIKey, err = strconv.Atoi(header["partner_id"]); err != nil {
+		return nil, fmt.Errorf("Token has an invalid partner_id: %s", err)
+	}
+	info.Signature = header["sig"]
+	info.SessionID = params["session_id"]
+	info.Role = Role(params["role"])
+	info.CreateTime, _ = strconv.ParseInt(params["create_time"], 10, 64)
+	info.ExpireTime, _ = strconv.ParseInt(params["expire_time"], 10, 64)
+	info.Nonce, _ = strconv.ParseInt(params["nonce"], 10, 64)
+
+	if len(info.SessionID) == 0 {
+		return nil, fmt.Errorf("Token has no session_id")
+	}
+	return info, nil
+}
+
+// SessionAPIKey returns the API key of the project a session
+// was created for. The API key is encoded in the session id
+func SessionAPIKey(sessionID string) (int, error) {
+	if len(sessionID) < 3 || sessionID[1] != '_' {
+		return 0, fmt.Errorf("Session id has an unknown format")
+	}
+
+	encoded := strings.NewReplacer("-", "+", "_", "/").
+		Replace(sessionID[2:])
+	encoded = strings.TrimRight(encoded, "=")
+
+	decoded, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("Session id could not be decoded: %s", err)
+	}
+
+	parts := strings.Split(string(decoded), "~")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("Session id has no API key")
+	}
+
+	apiKey, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("Session id has an invalid API key: %s", err)
+	}
+	return apiKey, nil
+}
+
+func parseTokenParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(s, "&") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = kv[1]
+		}
+	}
+	return params
+}
@@ -0,0 +1,147 @@
+package opentok
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StorageType is the kind of storage account where the archives
+// are uploaded once they have been recorded
+type StorageType string
+
+const (
+	// S3 uploads the archives to an Amazon S3 bucket or to any
+	// S3 compatible store when S3Config.Endpoint is set
+	S3 StorageType = "s3"
+
+	// Azure uploads the archives to a Microsoft Azure container
+	Azure StorageType = "azure"
+)
+
+// StorageFallback specifies what happens with an archive when
+// it cannot be uploaded to the configured storage
+type StorageFallback string
+
+const (
+	// FallbackNone is the default fallback. If the upload fails
+	// the archive status becomes failed
+	FallbackNone StorageFallback = "none"
+
+	// FallbackOpenTok makes the archive available in the OpenTok
+	// S3 account if the upload fails
+	FallbackOpenTok StorageFallback = "opentok"
+)
+
+// S3Config holds the settings to upload the archives to an
+// Amazon S3 bucket or an S3 compatible store
+type S3Config struct {
+	AccessKey string `json:"accessKey"`
+	Bucket    string `json:"bucket"`
+
+	// Endpoint is only needed for S3 compatible stores. It
+	// must be left empty to upload to Amazon S3
+	Endpoint  string `json:"endpoint,omitempty"`
+	SecretKey string `json:"secretKey"`
+}
+
+// AzureConfig holds the settings to upload the archives to
+// a Microsoft Azure container
+type AzureConfig struct {
+	AccountKey  string `json:"accountKey"`
+	AccountName string `json:"accountName"`
+	Container   string `json:"container"`
+
+	// Domain is optional and only needed when the storage
+	// account uses a custom domain
+	Domain string `json:"domain,omitempty"`
+}
+
+// StorageConfig is the upload target for the archives of the
+// project. Exactly one of S3 and Azure must be set, depending
+// on the value of Type
+type StorageConfig struct {
+	Type     StorageType
+	S3       *S3Config
+	Azure    *AzureConfig
+	Fallback StorageFallback
+}
+
+type jsonStorageConfig struct {
+	Config   json.RawMessage `json:"config"`
+	Fallback StorageFallback `json:"fallback"`
+	Type     StorageType     `json:"type"`
+}
+
+func (c *StorageConfig) validate() error {
+	switch c.Type {
+	case S3:
+		if c.S3 == nil || c.Azure != nil {
+			return fmt.Errorf("S3 storage needs S3 settings only")
+		}
+		if len(c.S3.Bucket) == 0 {
+			return fmt.Errorf("S3 bucket should not be empty")
+		}
+	case Azure:
+		if c.Azure == nil || c.S3 != nil {
+			return fmt.Errorf("Azure storage needs Azure settings only")
+		}
+		if len(c.Azure.Container) == 0 {
+			return fmt.Errorf("Azure container should not be empty")
+		}
+	default:
+		return fmt.Errorf("Unknown storage type: %q", c.Type)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the storage configuration in the format
+// expected by the OpenTok platform
+func (c *StorageConfig) MarshalJSON() ([]byte, error) {
+	var (
+		config []byte
+		err    error
+	)
+
+	if c.Type == Azure {
+		config, err = json.Marshal(c.Azure)
+	} else {
+		config, err = json.Marshal(c.S3)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&jsonStorageConfig{
+		Config:   config,
+		Fallback: c.Fallback,
+		Type:     c.Type,
+	})
+}
+
+// UnmarshalJSON decodes the storage configuration returned by
+// the OpenTok platform
+func (c *StorageConfig) UnmarshalJSON(data []byte) error {
+	var raw jsonStorageConfig
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	c.Type = raw.Type
+	c.Fallback = raw.Fallback
+	c.S3 = nil
+	c.Azure = nil
+
+	if len(raw.Config) == 0 {
+		return nil
+	}
+
+	switch raw.Type {
+	case S3:
+		c.S3 = &S3Config{}
+		return json.Unmarshal(raw.Config, c.S3)
+	case Azure:
+		c.Azure = &AzureConfig{}
+		return json.Unmarshal(raw.Config, c.Azure)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package opentok
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// User is the authenticated user that asks for a token
+type User struct {
+	ID         string
+	Attributes map[string]string
+}
+
+// Authenticator returns the user that made the request. It
+// returns an error if the request is not authenticated
+type Authenticator func(r *http.Request) (*User, error)
+
+// TokenPolicy decides the properties of the token that a user
+// gets to join a room. Returning an error denies the token and
+// returning nil props gives a token with the default properties
+type TokenPolicy interface {
+	TokenProps(user *User, room string) (*TokenProps, error)
+}
+
+// TokenPolicyFunc is an adapter to use a function as a
+// TokenPolicy
+type TokenPolicyFunc func(user *User, room string) (*TokenProps, error)
+
+// TokenProps calls f(user, room)
+func (f TokenPolicyFunc) TokenProps(user *User, room string) (*TokenProps, error) {
+	return f(user, room)
+}
+
+// RolePolicy is a TokenPolicy that chooses the role from an
+// attribute of the user
+type RolePolicy struct {
+	// RoleAttribute is the name of the user attribute whose value
+	// is looked up in Roles
+	RoleAttribute string
+	Roles         map[string]Role
+
+	// DefaultRole is used when the attribute is not in Roles.
+	// If it is empty, those users are denied a token
+	DefaultRole Role
+
+	// TTL is the lifetime of the tokens, a day if it is 0
+	TTL time.Duration
+
+	// Data is executed with the fields User and Room to generate
+	// the connection data of the token
+	Data *template.Template
+}
+
+// TokenProps returns the properties of the token for user
+func (p *RolePolicy) TokenProps(user *User, room string) (*TokenProps, error) {
+	role, ok := p.Roles[user.Attributes[p.RoleAttribute]]
+	if !ok {
+		role = p.DefaultRole
+	}
+	if len(role) == 0 {
+		return nil, fmt.Errorf("user %s has no role in room %s", user.ID, room)
+	}
+
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	props := &TokenProps{
+		Role:       role,
+		ExpireTime: time.Now().Add(ttl).Unix(),
+	}
+
+	if p.Data != nil {
+		var buf bytes.Buffer
+		err := p.Data.Execute(&buf, struct {
+			User *User
+			Room string
+		}{user, room})
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() >= 1000 {
+			return nil, fmt.Errorf("connection data must be shorter than 1000 bytes")
+		}
+		props.Data = buf.String()
+	}
+	return props, nil
+}
+
+// TokenResponse is the JSON document returned by TokenService
+type TokenResponse struct {
+	APIKey    int    `json:"apiKey"`
+	SessionID string `json:"sessionId"`
+	Token     string `json:"token"`
+}
+
+type tokenCacheKey struct {
+	user string
+	room string
+}
+
+type cachedToken struct {
+	props    TokenProps
+	response TokenResponse
+}
+
+// TokenService is an http.Handler that gives an authenticated
+// user the session id, a token and the API key to join a room.
+// The room is read from the room query or form parameter
+type TokenService struct {
+	ot     *OpenTok
+	rooms  *RoomManager
+	auth   Authenticator
+	policy TokenPolicy
+
+	limiter *keyedLimiter
+
+	mu    sync.Mutex
+	cache map[tokenCacheKey]*cachedToken
+}
+
+// NewTokenService creates a TokenService that generates the
+// tokens with ot for the sessions of rooms
+func NewTokenService(ot *OpenTok, rooms *RoomManager, auth Authenticator,
+	policy TokenPolicy) *TokenService {
+
+	return &TokenService{
+		ot:     ot,
+		rooms:  rooms,
+		auth:   auth,
+		policy: policy,
+		cache:  make(map[tokenCacheKey]*cachedToken),
+	}
+}
+
+// SetRateLimit limits every user to rate requests per second,
+// with bursts of up to burst requests. It must be called before
+// the service handles any request
+func (s *TokenService) SetRateLimit(rate float64, burst int) {
+	s.limiter = newKeyedLimiter(rate, burst)
+}
+
+// ServeHTTP authenticates the user, applies the policy and
+// writes a TokenResponse
+func (s *TokenService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	user, err := s.auth(r)
+	if err != nil || user == nil || len(user.ID) == 0 {
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if s.limiter != nil && !s.limiter.allow(user.ID, time.Now()) {
+		w.Header().Set("Retry-After", "1")
+		writeJSONError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	room := r.FormValue("room")
+	if len(room) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "room is required")
+		return
+	}
+
+	props, err := s.policy.TokenProps(user, room)
+	if err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if props == nil {
+		props = &TokenProps{}
+	}
+
+	res, err := s.token(user, room, props)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "token could not be generated")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
+
+// maxCachedTokens is the number of tokens a TokenService keeps
+const maxCachedTokens = 1024
+
+// token returns the cached token of user for room if it was
+// generated for the current session of the room with the same
+// role and data and it is still valid for at least half of its
+// lifetime
+func (s *TokenService) token(user *User, room string, props *TokenProps) (*TokenResponse, error) {
+	// the session of the room may have changed since the token
+	// was cached, e.g. after RoomManager.Remove
+	session, err := s.rooms.Session(room)
+	if err != nil {
+		return nil, err
+	}
+
+	key := tokenCacheKey{user.ID, room}
+	now := time.Now().Unix()
+
+	s.mu.Lock()
+	if cached, ok := s.cache[key]; ok {
+		remaining := cached.props.ExpireTime - now
+		lifetime := props.ExpireTime - now
+		if cached.response.SessionID == session.ID &&
+			cached.props.Role == props.Role && cached.props.Data == props.Data &&
+			remaining > lifetime/2 {
+			res := cached.response
+			s.mu.Unlock()
+			return &res, nil
+		}
+		delete(s.cache, key)
+	}
+	s.mu.Unlock()
+
+	token, err := s.ot.Token(session.ID, props)
+	if err != nil {
+		return nil, err
+	}
+
+	res := TokenResponse{
+		APIKey:    s.ot.APIKey,
+		SessionID: session.ID,
+		Token:     token.String(),
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxCachedTokens {
+		s.evict(now)
+	}
+	s.cache[key] = &cachedToken{props: *props, response: res}
+	s.mu.Unlock()
+
+	return &res, nil
+}
+
+// evict removes the expired tokens from the cache. If none has
+// expired, the token that expires first is removed. s.mu must be
+// held
+func (s *TokenService) evict(now int64) {
+	var (
+		first   tokenCacheKey
+		expires int64
+	)
+	for k, cached := range s.cache {
+		if cached.props.ExpireTime <= now {
+			delete(s.cache, k)
+			continue
+		}
+		if expires == 0 || cached.props.ExpireTime < expires {
+			first, expires = k, cached.props.ExpireTime
+		}
+	}
+	if len(s.cache) >= maxCachedTokens {
+		delete(s.cache, first)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,182 @@
+package opentok
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func newTestTokenService() *TokenService {
+	ot := New(apiKey, apiSecret)
+	rooms := NewRoomManager(&countingCreator{}, NewMemoryRoomStore())
+	auth := func(r *http.Request) (*User, error) {
+		id := r.Header.Get("X-User")
+		if len(id) == 0 {
+			return nil, fmt.Errorf("no user")
+		}
+		return &User{
+			ID:         id,
+			Attributes: map[string]string{"type": r.Header.Get("X-User-Type")},
+		}, nil
+	}
+	policy := &RolePolicy{
+		RoleAttribute: "type",
+		Roles: map[string]Role{
+			"host":  Moderator,
+			"guest": Subscriber,
+		},
+		TTL:  time.Hour,
+		Data: template.Must(template.New("data").Parse("user={{.User.ID}}")),
+	}
+	return NewTokenService(ot, rooms, auth, policy)
+}
+
+func requestToken(s *TokenService, user, userType, room string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/token?room="+room, nil)
+	if len(user) > 0 {
+		r.Header.Set("X-User", user)
+		r.Header.Set("X-User-Type", userType)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestTokenService(t *testing.T) {
+	s := newTestTokenService()
+
+	w := requestToken(s, "alice", "host", "lobby")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d: %s", w.Code, w.Body)
+	}
+
+	var res TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if res.APIKey != apiKey || len(res.SessionID) == 0 {
+		t.Fatalf("Unexpected response: %v", res)
+	}
+
+	info, err := DecodeToken(res.Token)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if info.SessionID != res.SessionID || info.Role != Moderator {
+		t.Fatalf("Unexpected token: %v", info)
+	}
+	if info.Data != "user=alice" {
+		t.Fatalf("Unexpected connection data: %s", info.Data)
+	}
+}
+
+func TestTokenServiceCache(t *testing.T) {
+	s := newTestTokenService()
+
+	var first, second, other TokenResponse
+	json.NewDecoder(requestToken(s, "alice", "host", "lobby").Body).Decode(&first)
+	json.NewDecoder(requestToken(s, "alice", "host", "lobby").Body).Decode(&second)
+	json.NewDecoder(requestToken(s, "alice", "guest", "lobby").Body).Decode(&other)
+
+	if first.Token != second.Token {
+		t.Fatalf("The cached token should be returned")
+	}
+	if first.Token == other.Token {
+		t.Fatalf("A different role should get a new token")
+	}
+	if first.SessionID != other.SessionID {
+		t.Fatalf("The same room should have the same session")
+	}
+}
+
+func TestTokenServiceRoomRemoved(t *testing.T) {
+	s := newTestTokenService()
+
+	var first, second TokenResponse
+	json.NewDecoder(requestToken(s, "alice", "host", "lobby").Body).Decode(&first)
+	if err := s.rooms.Remove("lobby"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	json.NewDecoder(requestToken(s, "alice", "host", "lobby").Body).Decode(&second)
+
+	if first.SessionID == second.SessionID || first.Token == second.Token {
+		t.Fatalf("The token of the removed session should not be returned")
+	}
+	info, err := DecodeToken(second.Token)
+	if err != nil || info.SessionID != second.SessionID {
+		t.Fatalf("Unexpected token: %v %v", info, err)
+	}
+}
+
+func TestTokenServiceCacheLimit(t *testing.T) {
+	s := newTestTokenService()
+
+	for i := 0; i < maxCachedTokens+10; i++ {
+		w := requestToken(s, fmt.Sprintf("user%d", i), "host", "lobby")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code: %d: %s", w.Code, w.Body)
+		}
+	}
+	if len(s.cache) > maxCachedTokens {
+		t.Fatalf("Unexpected cache size: %d", len(s.cache))
+	}
+}
+
+func TestTokenServiceNilProps(t *testing.T) {
+	s := newTestTokenService()
+	s.policy = TokenPolicyFunc(func(user *User, room string) (*TokenProps, error) {
+		return nil, nil
+	})
+
+	w := requestToken(s, "alice", "host", "lobby")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d: %s", w.Code, w.Body)
+	}
+	var res TokenResponse
+	json.NewDecoder(w.Body).Decode(&res)
+	info, err := DecodeToken(res.Token)
+	if err != nil || info.Role != Publisher {
+		t.Fatalf("Unexpected token: %v %v", info, err)
+	}
+}
+
+func TestTokenServiceErrors(t *testing.T) {
+	s := newTestTokenService()
+
+	if w := requestToken(s, "", "", "lobby"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401: %d", w.Code)
+	}
+	if w := requestToken(s, "alice", "host", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400: %d", w.Code)
+	}
+	if w := requestToken(s, "mallory", "unknown", "lobby"); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403: %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("DELETE", "/token?room=lobby", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405: %d", w.Code)
+	}
+}
+
+func TestTokenServiceRateLimit(t *testing.T) {
+	s := newTestTokenService()
+	s.SetRateLimit(0.001, 2)
+
+	for i := 0; i < 2; i++ {
+		if w := requestToken(s, "alice", "host", "lobby"); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code: %d", w.Code)
+		}
+	}
+	if w := requestToken(s, "alice", "host", "lobby"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429: %d", w.Code)
+	}
+	if w := requestToken(s, "bob", "host", "lobby"); w.Code != http.StatusOK {
+		t.Fatalf("Other users should not be limited: %d", w.Code)
+	}
+}
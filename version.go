@@ -0,0 +1,31 @@
+package opentok
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Version is the version of the SDK. It is sent in the User-Agent
+// header of every request
+const Version = "0.1.0"
+
+// userAgent identifies the SDK in the requests
+const userAgent = "OpenTok-Go-SDK/" + Version
+
+// SetUserAgentSuffix appends suffix, e.g. the name and version of
+// your application, to the User-Agent header of the requests. It
+// must be called before the OpenTok object is used
+func (ot *OpenTok) SetUserAgentSuffix(suffix string) {
+	// control characters are not allowed in a header
+	suffix = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, suffix))
+
+	ot.userAgent = userAgent
+	if len(suffix) > 0 {
+		ot.userAgent += " " + suffix
+	}
+}
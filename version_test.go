@@ -0,0 +1,25 @@
+package opentok
+
+import (
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	client, requests := respondInSequence(200, 200)
+	ot := newOpenTokWithClient(apiKey, apiSecret, client)
+
+	if _, err := ot.ArchiveGet("archiveId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if got := (*requests)[0].Header.Get("User-Agent"); got != "OpenTok-Go-SDK/"+Version {
+		t.Fatalf("Unexpected User-Agent: %q", got)
+	}
+
+	ot.SetUserAgentSuffix(" MyApp/1.2\r\n")
+	if _, err := ot.ArchiveGet("archiveId"); err != nil {
+		t.Fatalf("Expected err to be nil: %s", err)
+	}
+	if got := (*requests)[1].Header.Get("User-Agent"); got != "OpenTok-Go-SDK/"+Version+" MyApp/1.2" {
+		t.Fatalf("Unexpected User-Agent: %q", got)
+	}
+}